@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// last duration of each processing stage (see timeStage)
+type stageDurationLog struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+var stageDurations = stageDurationLog{durations: make(map[string]time.Duration)}
+
+func (l *stageDurationLog) set(stage string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.durations[stage] = d
+}
+
+func (l *stageDurationLog) seconds() map[string]float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result := make(map[string]float64, len(l.durations))
+	for stage, d := range l.durations {
+		result[stage] = d.Seconds()
+	}
+	return result
+}
+
+type AdminStatusResponse struct {
+	Url           string                  `json:"url"`
+	Timestamp     time.Time               `json:"timestamp"`
+	OffersSource  string                  `json:"offers_source"`
+	MasterUrl     string                  `json:"master_url,omitempty"`
+	OffersTs      time.Time               `json:"offers_timestamp,omitzero"`
+	Schedulers    []SchedulerStatus       `json:"schedulers"`
+	Sources       map[string]SourceStatus `json:"sources"`
+	StageDuration map[string]float64      `json:"stage_duration_seconds"`
+}
+
+type AdminRefreshResponse struct {
+	Triggered      []string `json:"triggered"`
+	AlreadyRunning []string `json:"already_running"`
+}
+
+// GET /admin/status                       state of schedulers and data sources
+// POST /admin/refresh                     start a cycle of all schedulers now
+// POST /admin/refresh?scheduler=NAME      start a cycle of one scheduler (market, account, invoices)
+type AdminHandler struct {
+	schedulers []*Scheduler
+}
+
+func (h *AdminHandler) status(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := AdminStatusResponse{
+		Url:           "/admin/status",
+		Timestamp:     time.Now().UTC(),
+		OffersSource:  "api",
+		MasterUrl:     *masterUrl,
+		OffersTs:      offerCache.Timestamp().UTC(),
+		Schedulers:    make([]SchedulerStatus, 0, len(h.schedulers)),
+		Sources:       health.status(),
+		StageDuration: stageDurations.seconds(),
+	}
+	if *masterUrl != "" {
+		resp.OffersSource = "master"
+	}
+	for _, s := range h.schedulers {
+		resp.Schedulers = append(resp.Schedulers, s.Status())
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJson(w, resp)
+}
+
+func (h *AdminHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := r.URL.Query().Get("scheduler")
+	resp := AdminRefreshResponse{Triggered: []string{}, AlreadyRunning: []string{}}
+	found := false
+	for _, s := range h.schedulers {
+		if name != "" && s.name != name {
+			continue
+		}
+		found = true
+		if s.Refresh() {
+			resp.Triggered = append(resp.Triggered, s.name)
+		} else {
+			resp.AlreadyRunning = append(resp.AlreadyRunning, s.name)
+		}
+	}
+	if !found {
+		http.Error(w, "unknown scheduler: "+name, http.StatusBadRequest)
+		return
+	}
+	slog.Info("Refresh requested by admin API", "triggered", resp.Triggered, "already_running", resp.AlreadyRunning)
+	writeJsonStatus(w, http.StatusAccepted, resp)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminRefresh(t *testing.T) {
+	market := newScheduler("market", func() time.Duration { return time.Minute }, nil)
+	account := newScheduler("account", func() time.Duration { return time.Minute }, nil)
+	h := &AdminHandler{schedulers: []*Scheduler{market, account}}
+
+	tests := []struct {
+		method         string
+		query          string
+		wantStatus     int
+		wantTriggered  int
+		wantAlreadyRun int
+	}{
+		{http.MethodPost, "?scheduler=market", http.StatusAccepted, 1, 0},
+		// market has a refresh pending
+		{http.MethodPost, "", http.StatusAccepted, 1, 1},
+		{http.MethodPost, "?scheduler=nope", http.StatusBadRequest, 0, 0},
+		{http.MethodGet, "", http.StatusMethodNotAllowed, 0, 0},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.refresh(w, httptest.NewRequest(tt.method, "/admin/refresh"+tt.query, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.query, w.Code, tt.wantStatus)
+			continue
+		}
+		if w.Code != http.StatusAccepted {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("got Content-Type %q", ct)
+		}
+		var resp AdminRefreshResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Triggered) != tt.wantTriggered || len(resp.AlreadyRunning) != tt.wantAlreadyRun {
+			t.Errorf("%s: got %+v", tt.query, resp)
+		}
+	}
+}
+
+func TestWriteJsonStatusMarshalError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeJsonStatus(w, http.StatusAccepted, map[string]any{"f": func() {}})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, want 500", w.Code)
+	}
+}
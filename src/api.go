@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -14,6 +16,8 @@ import (
 	"github.com/aquilax/truncate"
 	jsonv2 "github.com/go-json-experiment/json"
 	"github.com/go-json-experiment/json/jsontext"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type VastAiApiResults struct {
@@ -63,53 +67,70 @@ const defaultTimeout = 30 * time.Second
 const bundleTimeout = 120 * time.Second
 const queryInterval = 5 * time.Second
 
-func getVastAiInfo(masterUrl string) VastAiApiResults {
+// getMarketInfo fetches offers from the master exporter or from Vast.ai API
+func getMarketInfo(ctx context.Context, masterUrl string) VastAiApiResults {
 	result := VastAiApiResults{}
 
 	var err error
 	if masterUrl != "" {
 		// query offer from master exporter
-		err = getRawOffersFromMaster(masterUrl, &result)
+		err = getRawOffersFromMaster(ctx, masterUrl, &result)
 	} else {
 		// query offers from Vast.ai API
-		err = getRawOffersFromApi(&result)
-		time.Sleep(queryInterval)
+		err = getRawOffersFromApi(ctx, &result)
 	}
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not get offers", "err", err)
+		health.markFailed(sourceOffers, err)
+	} else {
+		health.markUpdated(sourceOffers)
 	}
+	return result
+}
 
-	if *apiKey == "" {
-		return result
-	}
+// getAccountInfo fetches machines and instances of the account
+func getAccountInfo(ctx context.Context) VastAiApiResults {
+	result := VastAiApiResults{ts: time.Now()}
 
 	var response1 struct {
 		Machines []VastAiMachine `json:"machines"`
 	}
-	if err := vastApiCall(&response1, "machines", nil, defaultTimeout); err != nil {
-		log.Println("ERROR:", err)
+	if err := vastApiCall(ctx, &response1, "machines", nil, defaultTimeout); err != nil {
+		slog.Error("Could not get machines", "err", err)
+		health.markFailed(sourceMachines, err)
 	} else {
 		result.myMachines = &response1.Machines
+		health.markUpdated(sourceMachines)
+	}
+	if ctx.Err() != nil {
+		return result
 	}
-	time.Sleep(queryInterval)
 
 	var response2 struct {
 		Instances []VastAiInstance `json:"instances"`
 	}
-	if err := vastApiCall(&response2, "instances", nil, defaultTimeout); err != nil {
-		log.Println("ERROR:", err)
+	if err := vastApiCall(ctx, &response2, "instances", nil, defaultTimeout); err != nil {
+		slog.Error("Could not get instances", "err", err)
+		health.markFailed(sourceInstances, err)
 	} else {
 		result.myInstances = &response2.Instances
+		health.markUpdated(sourceInstances)
 	}
-	time.Sleep(queryInterval)
+	return result
+}
 
-	payouts, err := getPayouts()
+// getInvoicesInfo fetches payouts of the account
+func getInvoicesInfo(ctx context.Context) VastAiApiResults {
+	result := VastAiApiResults{ts: time.Now()}
+
+	payouts, err := getPayouts(ctx)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not get payouts", "err", err)
+		health.markFailed(sourcePayouts, err)
 	} else {
 		result.payouts = payouts
+		health.markUpdated(sourcePayouts)
 	}
-
 	return result
 }
 
@@ -117,8 +138,17 @@ func (instance *VastAiInstance) isDefaultJob() bool {
 	return instance.BundleId == nil
 }
 
-func vastApiCall(result any, endpoint string, args url.Values, timeout time.Duration) error {
-	body, err := vastApiCallRaw(endpoint, args, timeout)
+func (instance *VastAiInstance) rentalType() string {
+	if instance.isDefaultJob() {
+		return "default"
+	} else if instance.IsBid {
+		return "bid"
+	}
+	return "ondemand"
+}
+
+func vastApiCall(ctx context.Context, result any, endpoint string, args url.Values, timeout time.Duration) error {
+	body, err := vastApiCallRaw(ctx, endpoint, args, timeout)
 	if err != nil {
 		return err
 	}
@@ -137,11 +167,15 @@ func vastApiCall(result any, endpoint string, args url.Values, timeout time.Dura
 	return nil
 }
 
-func vastApiCallRaw(endpoint string, args url.Values, timeout time.Duration) ([]byte, error) {
+func vastApiCallRaw(ctx context.Context, endpoint string, args url.Values, timeout time.Duration) (body []byte, err error) {
 	if body, ok := readTestData(endpoint); ok {
 		return body, nil
 	}
 
+	ctx, span := tracer.Start(ctx, "GET /"+endpoint, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("vastai.endpoint", endpoint)))
+	defer func() { endSpan(span, err) }()
+
 	if args == nil {
 		args = make(url.Values)
 	}
@@ -151,25 +185,33 @@ func vastApiCallRaw(endpoint string, args url.Values, timeout time.Duration) ([]
 
 	start := time.Now()
 
-	url := "https://console.vast.ai/api/v0/" + endpoint + "/?" + args.Encode()
-	req, err := http.NewRequest("GET", url, nil)
+	reqUrl := "https://console.vast.ai/api/v0/" + endpoint + "/?" + args.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", *userAgent)
+	req.Header.Set("User-Agent", settings().userAgent)
 
 	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
+		// keep the API key out of logs
+		if urlErr, ok := errors.AsType[*url.Error](err); ok {
+			urlErr.URL = "/" + endpoint
+		}
 		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err = io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", resp.StatusCode),
+		attribute.Int("http.response.body.size", len(body)),
+	)
 
 	if resp.StatusCode != http.StatusOK {
 		logErrorBody(body)
@@ -180,7 +222,7 @@ func vastApiCallRaw(endpoint string, args url.Values, timeout time.Duration) ([]
 	}
 
 	elapsed := time.Since(start)
-	log.Println("INFO: GET", url, "took", elapsed)
+	slog.Debug("API request", "endpoint", endpoint, "duration", elapsed, "bytes", len(body))
 
 	if metrics != nil {
 		metrics.ObserveAPIDuration(endpoint, elapsed.Seconds())
@@ -192,7 +234,7 @@ func vastApiCallRaw(endpoint string, args url.Values, timeout time.Duration) ([]
 
 func logErrorBody(body []byte) {
 	bodyStr := regexp.MustCompile(`\s+`).ReplaceAllString(strings.TrimSpace(string(body)), " ")
-	log.Println("ERROR:", truncate.Truncate(bodyStr, 200, "...", truncate.PositionEnd))
+	slog.Error("Unexpected response", "body", truncate.Truncate(bodyStr, 200, "...", truncate.PositionEnd))
 }
 
 func boolToFloat(v bool) float64 {
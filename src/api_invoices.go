@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/url"
 	"os"
 	"time"
@@ -37,11 +38,11 @@ type InvoiceState struct {
 	PaidOutCents int64          `json:"paidOutCents"`
 }
 
-func getPayouts() (*PayoutInfo, error) {
+func getPayouts(ctx context.Context) (*PayoutInfo, error) {
 	// old api — provides only recent invoices
 
 	var data VastAiInvoices
-	err := vastApiCall(&data, "users/current/invoices", nil, defaultTimeout)
+	err := vastApiCall(ctx, &data, "users/current/invoices", nil, defaultTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -68,16 +69,16 @@ func getPayouts() (*PayoutInfo, error) {
 	}
 
 	var data2 []VastAiInvoice2
-	err = vastApiCall(&data2, "invoices", args, defaultTimeout)
+	err = vastApiCall(ctx, &data2, "invoices", args, defaultTimeout)
 	if err != nil {
 		return nil, err
 	}
 
 	if state != nil {
-		log.Printf("INFO: received %d new invoices after %s", len(data2),
-			time.Unix(int64(state.LastInvoice.Ts), 0).Format(time.RFC3339))
+		slog.Info("Received new invoices", "count", len(data2),
+			"after", time.Unix(int64(state.LastInvoice.Ts), 0).UTC().Format(time.RFC3339))
 	} else {
-		log.Printf("INFO: received %d invoices (initial fetch)", len(data2))
+		slog.Info("Received invoices (initial fetch)", "count", len(data2))
 	}
 
 	paidOutCents := int64(0)
@@ -114,14 +115,14 @@ func readLastPayouts() *PayoutInfo {
 	j, err := os.ReadFile(*stateDir + "/.vastai_last_payouts")
 	if err != nil {
 		if !errors.Is(err, fs.ErrNotExist) {
-			log.Println("ERROR:", err)
+			slog.Error("Could not read payouts state", "err", err)
 		}
 		return nil
 	}
 	var payouts PayoutInfo
 	err = json.Unmarshal(j, &payouts)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not read payouts state", "err", err)
 		return nil
 	}
 	return &payouts
@@ -130,12 +131,12 @@ func readLastPayouts() *PayoutInfo {
 func storeLastPayouts(payouts *PayoutInfo) {
 	j, err := json.Marshal(payouts)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not store payouts state", "err", err)
 		return
 	}
-	err = os.WriteFile(*stateDir+"/.vastai_last_payouts", j, 0600)
+	err = writeFileAtomic(*stateDir+"/.vastai_last_payouts", j, 0600)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not store payouts state", "err", err)
 	}
 }
 
@@ -143,14 +144,14 @@ func readInvoiceState() *InvoiceState {
 	j, err := os.ReadFile(*stateDir + "/.vastai_invoice_state")
 	if err != nil {
 		if !errors.Is(err, fs.ErrNotExist) {
-			log.Println("ERROR:", err)
+			slog.Error("Could not read invoice state", "err", err)
 		}
 		return nil
 	}
 	var state InvoiceState
 	err = json.Unmarshal(j, &state)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not read invoice state", "err", err)
 		return nil
 	}
 	return &state
@@ -159,11 +160,11 @@ func readInvoiceState() *InvoiceState {
 func storeInvoiceState(state *InvoiceState) {
 	j, err := json.Marshal(state)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not store invoice state", "err", err)
 		return
 	}
-	err = os.WriteFile(*stateDir+"/.vastai_invoice_state", j, 0600)
+	err = writeFileAtomic(*stateDir+"/.vastai_invoice_state", j, 0600)
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not store invoice state", "err", err)
 	}
 }
@@ -3,7 +3,7 @@ package main
 import (
 	"cmp"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"slices"
 
@@ -18,6 +18,7 @@ type VastAiOffer struct {
 	GpuName    string
 	NumGpus    int
 	DphBase    float64
+	MinBid     float64
 	GpuFrac    float64
 	Score      float64
 	Rentable   bool
@@ -52,6 +53,7 @@ func (raw VastAiRawOffer) decode() (VastAiOffer, bool) {
 
 	verified, _ := raw["verified"].(bool)
 	score, _ := raw["score"].(float64)
+	minBid, _ := raw["min_bid"].(float64)
 	dlperf, _ := raw["dlperf"].(float64)
 	tflops, _ := raw["total_flops"].(float64)
 	vram, _ := raw["gpu_ram"].(float64)
@@ -89,6 +91,7 @@ func (raw VastAiRawOffer) decode() (VastAiOffer, bool) {
 		GpuName:    gpuName,
 		NumGpus:    int(numGpus),
 		DphBase:    dphBase,
+		MinBid:     minBid,
 		GpuFrac:    gpuFrac,
 		Score:      score,
 		Rentable:   rentable,
@@ -107,13 +110,16 @@ func (raw VastAiRawOffer) decode() (VastAiOffer, bool) {
 	}, true
 }
 
-func (rawOffers VastAiRawOffers) decode() VastAiOffers {
+func (rawOffers VastAiRawOffers) decode(anomalies *OfferAnomalies) VastAiOffers {
 	result := make(VastAiOffers, 0, len(rawOffers))
 	for _, raw := range rawOffers {
 		if offer, ok := raw.decode(); ok {
 			result = append(result, offer)
 		} else {
-			log.Println("WARN:", fmt.Sprintf("Offer is missing required fields: %v", raw))
+			warnLimited("offer_missing_fields", "Offer is missing required fields", "offer", fmt.Sprint(raw))
+			id, _ := raw["id"].(float64)
+			machineId, _ := raw["machine_id"].(float64)
+			anomalies.add(anomalyMissingFields, int(machineId), []int{int(id)}, nil)
 		}
 	}
 
@@ -129,8 +135,13 @@ func (rawOffers VastAiRawOffers) decode() VastAiOffers {
 	totalDups := 0
 	n := 0
 	for _, offer := range result {
-		if offer.Id == 0 || (n > 0 && offer.Id == result[n-1].Id) {
+		if offer.Id == 0 {
+			anomalies.add(anomalyMissingId, offer.MachineId, nil, nil)
+			continue
+		}
+		if n > 0 && offer.Id == result[n-1].Id {
 			totalDups++
+			anomalies.add(anomalyDuplicateOffer, offer.MachineId, []int{offer.Id}, nil)
 			continue
 		}
 		result[n] = offer
@@ -138,7 +149,7 @@ func (rawOffers VastAiRawOffers) decode() VastAiOffers {
 	}
 	result = result[:n]
 	if totalDups > 0 {
-		log.Println("WARN:", fmt.Sprintf("Removed %d duplicate offers", totalDups))
+		slog.Warn("Removed duplicate offers", "count", totalDups)
 	}
 
 	// final sort: machine_id desc, id asc
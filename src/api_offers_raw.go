@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"math"
@@ -11,22 +12,28 @@ import (
 	"strings"
 	"time"
 
-	"log"
+	"log/slog"
 
 	jsonv2 "github.com/go-json-experiment/json"
 	"github.com/go-json-experiment/json/jsontext"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type VastAiRawOffer map[string]any
 type VastAiRawOffers []VastAiRawOffer
 
-func getRawOffersFromMaster(masterUrl string, result *VastAiApiResults) error {
+func getRawOffersFromMaster(ctx context.Context, masterUrl string, result *VastAiApiResults) (err error) {
 	url := strings.TrimRight(masterUrl, "/") + "/offers"
 
+	ctx, span := tracer.Start(ctx, "GET master/offers", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("url.full", url)))
+	defer func() { endSpan(span, err) }()
+
 	start := time.Now()
 
 	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
@@ -39,9 +46,10 @@ func getRawOffersFromMaster(masterUrl string, result *VastAiApiResults) error {
 		return err
 	}
 	defer func() { _ = resp.Body.Close() }()
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 
 	if resp.StatusCode == http.StatusNotModified {
-		log.Println("INFO: Master returned 304 Not Modified")
+		slog.Debug("Master returned 304 Not Modified")
 		return nil
 	}
 
@@ -58,7 +66,8 @@ func getRawOffersFromMaster(masterUrl string, result *VastAiApiResults) error {
 	}
 
 	elapsed := time.Since(start)
-	log.Println("INFO: GET", url, "took", elapsed)
+	slog.Debug("Master request", "url", url, "duration", elapsed, "bytes", len(body))
+	span.SetAttributes(attribute.Int("http.response.body.size", len(body)))
 
 	if metrics != nil {
 		metrics.ObserveAPIDuration("master/offers", elapsed.Seconds())
@@ -88,12 +97,12 @@ func getRawOffersFromMaster(masterUrl string, result *VastAiApiResults) error {
 	return nil
 }
 
-func getRawOffersFromApi(result *VastAiApiResults) error {
+func getRawOffersFromApi(ctx context.Context, result *VastAiApiResults) error {
 	var t struct {
 		Offers VastAiRawOffers `json:"offers"`
 	}
 
-	if err := vastApiCall(&t, "bundles", url.Values{
+	if err := vastApiCall(ctx, &t, "bundles", url.Values{
 		"q": {`{"external":{"eq":"false"},"type":"on-demand","disable_bundling":true}`},
 	}, bundleTimeout); err != nil {
 		return err
@@ -130,7 +139,7 @@ func (offer VastAiRawOffer) fixFloats() {
 	for k, v := range offer {
 		if fv, ok := v.(float64); ok {
 			if math.IsInf(fv, 0) || math.IsNaN(fv) {
-				log.Println("WARN:", fmt.Sprintf("Inf or NaN found with key '%s' in %v", k, offer))
+				warnLimited("offer_inf_nan", "Inf or NaN found in offer", "key", k, "offer", fmt.Sprint(offer))
 				offer[k] = nil
 			}
 		}
@@ -1,10 +1,12 @@
 package main
 
 import (
-	"errors"
-	"log"
+	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -17,9 +19,21 @@ type instanceInfo struct {
 type instanceInfoMap map[int]*instanceInfo
 
 type VastAiAccountCollector struct {
+	// machines/instances and invoices are updated by different schedulers
+	mu sync.Mutex
+
 	knownInstances instanceInfoMap
+	knownGpuSlots  map[int]int
+	instanceEvents *InstanceEventLog
+	machineHealth  *MachineHealthTracker
 	lastPayouts    *PayoutInfo
 
+	// GPU models of the account's machines, nil until machines are loaded
+	myGpus []string
+	// offer snapshot and GPU models used for market-derived metrics, these are only recalculated when changed
+	marketTs   time.Time
+	marketGpus []string
+
 	VastAiPriceStatsCollectorV1
 	VastAiPriceStatsCollectorV2
 
@@ -40,11 +54,22 @@ type VastAiAccountCollector struct {
 	machine_per_gpu_dlperf_score_chunk *prometheus.GaugeVec
 	machine_per_gpu_dlperf_score_whole *prometheus.GaugeVec
 
+	machine_reliability_change_24h *prometheus.GaugeVec
+	machine_offline_seconds_total  *prometheus.CounterVec
+	machine_incidents_total        *prometheus.CounterVec
+
 	machine_ondemand_price_per_gpu_dollars *prometheus.GaugeVec
 	machine_gpu_count                      *prometheus.GaugeVec
 	machine_rentals_count                  *prometheus.GaugeVec
 	machine_used_gpu_count                 *prometheus.GaugeVec
 
+	machine_market_rank      *prometheus.GaugeVec
+	machine_comparable_count *prometheus.GaugeVec
+
+	machine_gpu_slot_state    *prometheus.GaugeVec
+	machine_gpu_slot_info     *prometheus.GaugeVec
+	machine_gpu_slot_rentable *prometheus.GaugeVec
+
 	instance_info                    *prometheus.GaugeVec
 	instance_is_running              *prometheus.GaugeVec
 	instance_my_bid_per_gpu_dollars  *prometheus.GaugeVec
@@ -52,6 +77,14 @@ type VastAiAccountCollector struct {
 	instance_start_timestamp         *prometheus.GaugeVec
 	instance_gpu_count               *prometheus.GaugeVec
 	instance_gpu_fraction            *prometheus.GaugeVec
+
+	instance_bid_margin_per_gpu_dollars *prometheus.GaugeVec
+	instance_is_outbid                  *prometheus.GaugeVec
+	instance_seconds_since_outbid       *prometheus.GaugeVec
+
+	instance_rental_duration_seconds *prometheus.HistogramVec
+
+	bid_ladder_gpu_count *TrackedGaugeVec
 }
 
 func newVastAiAccountCollector() *VastAiAccountCollector {
@@ -60,8 +93,11 @@ func newVastAiAccountCollector() *VastAiAccountCollector {
 	instanceLabelNames := []string{"instance_id", "machine_id", "rental_type"}
 	instanceInfoLabelNamess := append(append([]string{}, instanceLabelNames...), "docker_image", "gpu_name")
 
-	return &VastAiAccountCollector{
+	e := &VastAiAccountCollector{
 		knownInstances: make(instanceInfoMap),
+		knownGpuSlots:  make(map[int]int),
+		instanceEvents: loadInstanceEventLog(),
+		machineHealth:  loadMachineHealthTracker(),
 		lastPayouts:    readLastPayouts(),
 
 		VastAiPriceStatsCollectorV1: newVastAiPriceStatsCollectorV1(),
@@ -145,6 +181,22 @@ func newVastAiAccountCollector() *VastAiAccountCollector {
 			Help:      "DLPerf score per GPU (measured on the whole machine)",
 		}, []string{"machine_id"}),
 
+		machine_reliability_change_24h: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "machine_reliability_change_24h",
+			Help:      "Change of reliability indicator over the last 24 hours",
+		}, []string{"machine_id"}),
+		machine_offline_seconds_total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "machine_offline_seconds_total",
+			Help:      "Total time the machine was seen offline (persisted across restarts)",
+		}, []string{"machine_id"}),
+		machine_incidents_total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "machine_incidents_total",
+			Help:      "Total number of machine incidents (kind = 'offline'/'reliability_drop', persisted across restarts)",
+		}, []string{"machine_id", "kind"}),
+
 		machine_ondemand_price_per_gpu_dollars: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "machine_ondemand_price_per_gpu_dollars",
@@ -166,6 +218,33 @@ func newVastAiAccountCollector() *VastAiAccountCollector {
 			Help:      "Number of GPUs running jobs (rental_type = 'ondemand'/'reserved'/'bid'/'default'/'my')",
 		}, []string{"machine_id", "rental_type"}),
 
+		machine_market_rank: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "machine_market_rank",
+			Help:      "Rank of the machine among comparable offers, 1 is best (sort_by = 'price'/'dlperf_per_dollar'/'score')",
+		}, []string{"machine_id", "sort_by"}),
+		machine_comparable_count: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "machine_comparable_count",
+			Help:      "Number of comparable machines on the market (same GPU model, verified status and GPU count), including this one",
+		}, []string{"machine_id"}),
+
+		machine_gpu_slot_state: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "machine_gpu_slot_state",
+			Help:      "Current state of the GPU slot (state = 'ondemand'/'reserved'/'bid'/'free'/'unknown')",
+		}, []string{"machine_id", "gpu_index", "state"}),
+		machine_gpu_slot_info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "machine_gpu_slot_info",
+			Help:      "GPU slot info, gpu_id is taken from market offers (only for listed machines)",
+		}, []string{"machine_id", "gpu_index", "gpu_id"}),
+		machine_gpu_slot_rentable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "machine_gpu_slot_rentable",
+			Help:      "Is GPU slot offered in a rentable chunk on the market (1) or not (0) (only for listed machines)",
+		}, []string{"machine_id", "gpu_index"}),
+
 		instance_info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "instance_info",
@@ -202,7 +281,59 @@ func newVastAiAccountCollector() *VastAiAccountCollector {
 			Name:      "instance_gpu_fraction",
 			Help:      "Number of GPUs assigned to this instance divided by total number of GPUs on the host",
 		}, instanceLabelNames),
+
+		instance_bid_margin_per_gpu_dollars: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "instance_bid_margin_per_gpu_dollars",
+			Help:      "My bid minus min bid to outbid this instance per GPU/hour, negative if outbid (rental_type = 'default'/'bid' only)",
+		}, instanceLabelNames),
+		instance_is_outbid: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "instance_is_outbid",
+			Help:      "Is instance currently outbid (1) or not (0) (rental_type = 'default'/'bid' only)",
+		}, instanceLabelNames),
+		instance_seconds_since_outbid: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "instance_seconds_since_outbid",
+			Help:      "Seconds since this instance was last outbid (absent if it was never outbid)",
+		}, instanceLabelNames),
+
+		instance_rental_duration_seconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "instance_rental_duration_seconds",
+			Help:      "Duration of finished rentals, from start to destruction (rental_type = 'ondemand'/'bid'/'default')",
+			Buckets: []float64{
+				5 * 60, 15 * 60, 3600, 3 * 3600, 6 * 3600, 12 * 3600,
+				86400, 2 * 86400, 4 * 86400, 7 * 86400, 14 * 86400, 30 * 86400,
+			},
+		}, []string{"rental_type", "gpu_name"}),
+
+		bid_ladder_gpu_count: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "bid_ladder_gpu_count",
+			Help:      "Number of available (not rented) GPUs offered for interruptible rental with min bid per GPU/hour at or below 'bid' (in dollars)",
+		}, []string{"gpu_name", "bid"}),
+	}
+
+	// restore persisted counters
+	offlineSeconds, incidents := e.machineHealth.Totals()
+	for id, seconds := range offlineSeconds {
+		e.machine_offline_seconds_total.WithLabelValues(strconv.Itoa(id)).Add(seconds)
+	}
+	for id, counts := range incidents {
+		for kind, count := range counts {
+			e.machine_incidents_total.WithLabelValues(strconv.Itoa(id), kind).Add(float64(count))
+		}
 	}
+
+	// payouts from the previous run until invoices are fetched
+	if e.lastPayouts != nil {
+		e.pending_payout_dollars.Set(e.lastPayouts.PendingPayout)
+		e.paid_out_dollars.Set(e.lastPayouts.PaidOut)
+		e.last_payout_time.Set(e.lastPayouts.LastPayoutTime)
+	}
+
+	return e
 }
 
 func (e *VastAiAccountCollector) Describe(ch chan<- *prometheus.Desc) {
@@ -226,11 +357,22 @@ func (e *VastAiAccountCollector) Describe(ch chan<- *prometheus.Desc) {
 	e.machine_per_gpu_dlperf_score_chunk.Describe(ch)
 	e.machine_per_gpu_dlperf_score_whole.Describe(ch)
 
+	e.machine_reliability_change_24h.Describe(ch)
+	e.machine_offline_seconds_total.Describe(ch)
+	e.machine_incidents_total.Describe(ch)
+
 	e.machine_ondemand_price_per_gpu_dollars.Describe(ch)
 	e.machine_gpu_count.Describe(ch)
 	e.machine_rentals_count.Describe(ch)
 	e.machine_used_gpu_count.Describe(ch)
 
+	e.machine_market_rank.Describe(ch)
+	e.machine_comparable_count.Describe(ch)
+
+	e.machine_gpu_slot_state.Describe(ch)
+	e.machine_gpu_slot_info.Describe(ch)
+	e.machine_gpu_slot_rentable.Describe(ch)
+
 	e.instance_info.Describe(ch)
 	e.instance_is_running.Describe(ch)
 	e.instance_my_bid_per_gpu_dollars.Describe(ch)
@@ -238,6 +380,14 @@ func (e *VastAiAccountCollector) Describe(ch chan<- *prometheus.Desc) {
 	e.instance_start_timestamp.Describe(ch)
 	e.instance_gpu_count.Describe(ch)
 	e.instance_gpu_fraction.Describe(ch)
+
+	e.instance_bid_margin_per_gpu_dollars.Describe(ch)
+	e.instance_is_outbid.Describe(ch)
+	e.instance_seconds_since_outbid.Describe(ch)
+
+	e.instance_rental_duration_seconds.Describe(ch)
+
+	e.bid_ladder_gpu_count.Describe(ch)
 }
 
 func (e *VastAiAccountCollector) Collect(ch chan<- prometheus.Metric) {
@@ -261,11 +411,22 @@ func (e *VastAiAccountCollector) Collect(ch chan<- prometheus.Metric) {
 	e.machine_per_gpu_dlperf_score_chunk.Collect(ch)
 	e.machine_per_gpu_dlperf_score_whole.Collect(ch)
 
+	e.machine_reliability_change_24h.Collect(ch)
+	e.machine_offline_seconds_total.Collect(ch)
+	e.machine_incidents_total.Collect(ch)
+
 	e.machine_ondemand_price_per_gpu_dollars.Collect(ch)
 	e.machine_gpu_count.Collect(ch)
 	e.machine_rentals_count.Collect(ch)
 	e.machine_used_gpu_count.Collect(ch)
 
+	e.machine_market_rank.Collect(ch)
+	e.machine_comparable_count.Collect(ch)
+
+	e.machine_gpu_slot_state.Collect(ch)
+	e.machine_gpu_slot_info.Collect(ch)
+	e.machine_gpu_slot_rentable.Collect(ch)
+
 	e.instance_info.Collect(ch)
 	e.instance_is_running.Collect(ch)
 	e.instance_my_bid_per_gpu_dollars.Collect(ch)
@@ -273,27 +434,59 @@ func (e *VastAiAccountCollector) Collect(ch chan<- prometheus.Metric) {
 	e.instance_start_timestamp.Collect(ch)
 	e.instance_gpu_count.Collect(ch)
 	e.instance_gpu_fraction.Collect(ch)
+
+	e.instance_bid_margin_per_gpu_dollars.Collect(ch)
+	e.instance_is_outbid.Collect(ch)
+	e.instance_seconds_since_outbid.Collect(ch)
+
+	e.instance_rental_duration_seconds.Collect(ch)
+
+	e.bid_ladder_gpu_count.Collect(ch)
 }
 
+// UpdateFrom updates metrics from whatever parts of the account data are present in info
 func (e *VastAiAccountCollector) UpdateFrom(info VastAiApiResults, offerCache *OfferCacheSnapshot) {
-	defer timeStage("metrics_account")()
-
-	e.UpdateMachinesAndInstances(info, offerCache)
-	e.UpdatePayouts(info)
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	machinesCount := -1
 	if info.myMachines != nil {
-		machinesCount = len(*info.myMachines)
-	}
-	instancesCount := -1
-	if info.myInstances != nil {
-		instancesCount = len(*info.myInstances)
+		done := timeStage("metrics_account")
+		e.UpdateMachinesAndInstances(info, offerCache)
+		done()
+
+		instancesCount := -1
+		if info.myInstances != nil {
+			instancesCount = len(*info.myInstances)
+		}
+		slog.Debug("Updated machines", "machines", len(*info.myMachines), "instances", instancesCount)
 	}
-	var payoutsInfo any = "(nil)"
 	if info.payouts != nil {
-		payoutsInfo = *info.payouts
+		e.UpdatePayouts(info)
+		slog.Info("Updated payouts", "paid_out", info.payouts.PaidOut, "pending_payout", info.payouts.PendingPayout)
+	}
+}
+
+// UpdateMarket recomputes market-derived metrics of the account's GPU models after each update of offers,
+// GPU models are taken from the last update of machines
+func (e *VastAiAccountCollector) UpdateMarket(offerCache *OfferCacheSnapshot) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.myGpus != nil {
+		e.updateMarketMetrics(offerCache, e.myGpus)
 	}
-	log.Println("INFO:", machinesCount, "my machines,", instancesCount, "my instances, payouts:", payoutsInfo)
+}
+
+// updateMarketMetrics processes offers, unless neither offers nor my GPU models changed since the previous update
+func (e *VastAiAccountCollector) updateMarketMetrics(offerCache *OfferCacheSnapshot, myGpus []string) {
+	if offerCache.ts.IsZero() || (offerCache.ts.Equal(e.marketTs) && slices.Equal(myGpus, e.marketGpus)) {
+		return
+	}
+	e.VastAiPriceStatsCollectorV1.UpdateFrom(offerCache, myGpus)
+	e.VastAiPriceStatsCollectorV2.UpdateFrom(offerCache, myGpus)
+	e.UpdateBidLadder(offerCache, myGpus)
+	e.marketTs = offerCache.ts
+	e.marketGpus = myGpus
 }
 
 func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResults, offerCache *OfferCacheSnapshot) {
@@ -301,6 +494,8 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 		return
 	}
 
+	now := time.Now()
+
 	isMyMachineId := make(map[int]bool)
 	numGpus := make(map[int]int)
 	myGpus := []string{}
@@ -310,9 +505,18 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 		numGpus[machine.Id] = machine.NumGpus
 	}
 
-	// process offers
-	e.VastAiPriceStatsCollectorV1.UpdateFrom(offerCache, myGpus)
-	e.VastAiPriceStatsCollectorV2.UpdateFrom(offerCache, myGpus)
+	e.myGpus = myGpus
+	e.updateMarketMetrics(offerCache, myGpus)
+
+	// track offline incidents and reliability drops
+	health := e.machineHealth.UpdateFrom(*info.myMachines, now)
+	for id, seconds := range health.OfflineSeconds {
+		e.machine_offline_seconds_total.WithLabelValues(strconv.Itoa(id)).Add(seconds)
+	}
+	for _, incident := range health.Incidents {
+		slog.Warn("Machine incident", "machine_id", incident.MachineId, "kind", incident.Kind)
+		e.machine_incidents_total.WithLabelValues(strconv.Itoa(incident.MachineId), incident.Kind).Inc()
+	}
 
 	// process machines
 	// TODO handle disappeared machines, changed hostnames, gpu names, ip addresses
@@ -334,6 +538,9 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 		e.machine_is_listed.With(labels).Set(boolToFloat(machine.Listed))
 		e.machine_is_online.With(labels).Set(boolToFloat(machine.Timeout == 0))
 		e.machine_reliability.With(labels).Set(machine.Reliability)
+		if change, ok := e.machineHealth.ReliabilityChange(machine.Id, machine.Reliability, 24*time.Hour, now); ok {
+			e.machine_reliability_change_24h.With(labels).Set(change)
+		}
 		e.machine_per_gpu_teraflops.With(labels).Set(machine.TFlops / float64(machine.NumGpus))
 
 		// inet up/down
@@ -359,8 +566,10 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 		t.With(prometheus.Labels{"rental_type": "bid", "rental_status": "stopped"}).Set(float64(countBidStopped))
 
 		// get dlperf etc from offer list
+		var marketOffer *VastAiMachineOffer
 		for _, offer := range offerCache.machines {
 			if offer.MachineId == machine.Id {
+				marketOffer = &offer
 				if offer.DlperfPerGpuChunk > 0 {
 					e.machine_per_gpu_dlperf_score_chunk.With(labels).Set(offer.DlperfPerGpuChunk)
 				}
@@ -374,6 +583,21 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 			}
 		}
 
+		e.UpdateGpuSlots(machine, marketOffer)
+
+		// rank among comparable offers
+		if marketOffer != nil {
+			rank := offerCache.machines.marketRank(marketOffer)
+			t := e.machine_market_rank.MustCurryWith(labels)
+			t.With(prometheus.Labels{"sort_by": "price"}).Set(float64(rank.Price))
+			t.With(prometheus.Labels{"sort_by": "dlperf_per_dollar"}).Set(float64(rank.DlperfPerDollar))
+			t.With(prometheus.Labels{"sort_by": "score"}).Set(float64(rank.Score))
+			e.machine_comparable_count.With(labels).Set(float64(rank.Comparable))
+		} else {
+			e.machine_market_rank.DeletePartialMatch(labels)
+			e.machine_comparable_count.Delete(labels)
+		}
+
 		// count my/default jobs
 		if info.myInstances != nil {
 			defJobsRunning := 0
@@ -424,22 +648,26 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 
 	// process instances
 	if info.myInstances != nil {
+		// record lifecycle events
+		for _, event := range e.instanceEvents.UpdateFrom(*info.myInstances, isMyMachineId, now) {
+			slog.Info("Instance event", "instance_id", event.InstanceId, "machine_id", event.MachineId, "event", event.Event)
+			if event.Event == "destroyed" {
+				e.instance_rental_duration_seconds.
+					With(prometheus.Labels{"rental_type": event.RentalType, "gpu_name": event.GpuName}).
+					Observe(event.Duration)
+			}
+		}
+
 		for _, t := range e.knownInstances {
 			t.keep = false
 		}
 
 		for _, instance := range *info.myInstances {
 			if isMyMachineId[instance.MachineId] {
-				rentalType := "ondemand"
-				if instance.isDefaultJob() {
-					rentalType = "default"
-				} else if instance.IsBid {
-					rentalType = "bid"
-				}
 				labels := prometheus.Labels{
 					"instance_id": strconv.Itoa(instance.Id),
 					"machine_id":  strconv.Itoa(instance.MachineId),
-					"rental_type": rentalType,
+					"rental_type": instance.rentalType(),
 				}
 
 				e.instance_info.
@@ -456,6 +684,17 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 				e.instance_gpu_count.With(labels).Set(float64(instance.NumGpus))
 				e.instance_gpu_fraction.With(labels).Set(float64(instance.NumGpus) / float64(numGpus[instance.MachineId]))
 
+				if instance.rentalType() == "ondemand" {
+					e.instance_bid_margin_per_gpu_dollars.Delete(labels)
+					e.instance_is_outbid.Delete(labels)
+				} else {
+					e.instance_bid_margin_per_gpu_dollars.With(labels).Set(instance.DphBase - instance.MinBid)
+					e.instance_is_outbid.With(labels).Set(boolToFloat(instance.MinBid > instance.DphBase))
+				}
+				if lastOutbid := e.instanceEvents.LastOutbid(instance.Id); !lastOutbid.IsZero() {
+					e.instance_seconds_since_outbid.With(labels).Set(now.Sub(lastOutbid).Seconds())
+				}
+
 				e.knownInstances[instance.Id] = &instanceInfo{&labels, true}
 			}
 		}
@@ -471,12 +710,72 @@ func (e *VastAiAccountCollector) UpdateMachinesAndInstances(info VastAiApiResult
 				e.instance_start_timestamp.Delete(*labels)
 				e.instance_gpu_count.Delete(*labels)
 				e.instance_gpu_fraction.Delete(*labels)
+				e.instance_bid_margin_per_gpu_dollars.Delete(*labels)
+				e.instance_is_outbid.Delete(*labels)
+				e.instance_seconds_since_outbid.Delete(*labels)
 				delete(e.knownInstances, id)
 			}
 		}
 	}
 }
 
+func (e *VastAiAccountCollector) UpdateGpuSlots(machine VastAiMachine, marketOffer *VastAiMachineOffer) {
+	machineId := strconv.Itoa(machine.Id)
+	slots := parseGpuOccupancy(machine.GpuOccupancy)
+
+	// gpu_ids of the whole machine are sorted, so i-th id corresponds to i-th slot
+	var gpuIds []int
+	var rentable map[int]bool
+	if marketOffer != nil && len(marketOffer.GpuIds) == len(slots) {
+		gpuIds = marketOffer.GpuIds
+		rentable = marketOffer.rentableGpuIds()
+	}
+
+	for i, state := range slots {
+		labels := prometheus.Labels{"machine_id": machineId, "gpu_index": strconv.Itoa(i)}
+		t := e.machine_gpu_slot_state.MustCurryWith(labels)
+		for _, s := range gpuSlotStates {
+			if s != state {
+				t.Delete(prometheus.Labels{"state": s})
+			}
+		}
+		t.With(prometheus.Labels{"state": state}).Set(1)
+
+		if gpuIds != nil {
+			e.machine_gpu_slot_info.DeletePartialMatch(labels)
+			e.machine_gpu_slot_info.MustCurryWith(labels).
+				With(prometheus.Labels{"gpu_id": strconv.Itoa(gpuIds[i])}).
+				Set(1)
+			e.machine_gpu_slot_rentable.With(labels).Set(boolToFloat(rentable[gpuIds[i]]))
+		} else {
+			e.machine_gpu_slot_info.DeletePartialMatch(labels)
+			e.machine_gpu_slot_rentable.Delete(labels)
+		}
+	}
+
+	// remove metrics for slots which are gone
+	for i := len(slots); i < e.knownGpuSlots[machine.Id]; i++ {
+		labels := prometheus.Labels{"machine_id": machineId, "gpu_index": strconv.Itoa(i)}
+		e.machine_gpu_slot_state.DeletePartialMatch(labels)
+		e.machine_gpu_slot_info.DeletePartialMatch(labels)
+		e.machine_gpu_slot_rentable.Delete(labels)
+	}
+	e.knownGpuSlots[machine.Id] = len(slots)
+}
+
+func (e *VastAiAccountCollector) UpdateBidLadder(offerCache *OfferCacheSnapshot, gpuNames []string) {
+	grouped := offerCache.machines.groupByGpu()
+	for _, gpuName := range gpuNames {
+		counts := grouped[gpuName].bidLadder(bidLadderLevels)
+		for i, level := range bidLadderLevels {
+			labels := prometheus.Labels{"gpu_name": gpuName, "bid": strconv.FormatFloat(level, 'f', -1, 64)}
+			e.bid_ladder_gpu_count.Set(labels, float64(counts[i]))
+		}
+	}
+	// GPU models which are no longer on the account's machines
+	e.bid_ladder_gpu_count.Sweep()
+}
+
 func (e *VastAiAccountCollector) UpdatePayouts(info VastAiApiResults) {
 	if info.payouts == nil {
 		return
@@ -496,19 +795,3 @@ func (e *VastAiAccountCollector) UpdatePayouts(info VastAiApiResults) {
 		storeLastPayouts(info.payouts)
 	}
 }
-
-func (e *VastAiAccountCollector) InitialUpdateFrom(info VastAiApiResults, offerCache *OfferCacheSnapshot) error {
-	if info.myInstances == nil || info.myMachines == nil || info.payouts == nil {
-		return errors.New("could not read all required data from Vast.ai")
-	}
-
-	if e.lastPayouts != nil {
-		e.pending_payout_dollars.Set(e.lastPayouts.PendingPayout)
-		e.paid_out_dollars.Set(e.lastPayouts.PaidOut)
-		e.last_payout_time.Set(e.lastPayouts.LastPayoutTime)
-	}
-
-	e.UpdateFrom(info, offerCache)
-
-	return nil
-}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUpdateBidLadderDeletesGoneGpuModels(t *testing.T) {
+	prevStateDir := *stateDir
+	*stateDir = t.TempDir()
+	t.Cleanup(func() { *stateDir = prevStateDir })
+
+	e := newVastAiAccountCollector()
+	snap := &OfferCacheSnapshot{machines: VastAiMachineOffers{
+		{MachineId: 1, GpuName: "RTX 4090", NumGpus: 2, MinBidPerGpu: 0.2},
+		{MachineId: 2, GpuName: "RTX 3090", NumGpus: 1, MinBidPerGpu: 0.1},
+	}}
+
+	e.UpdateBidLadder(snap, []string{"RTX 4090", "RTX 3090"})
+	if n := testutil.CollectAndCount(e.bid_ladder_gpu_count); n != 2*len(bidLadderLevels) {
+		t.Fatalf("got %d series, want %d", n, 2*len(bidLadderLevels))
+	}
+
+	e.UpdateBidLadder(snap, []string{"RTX 4090"})
+	if n := testutil.CollectAndCount(e.bid_ladder_gpu_count); n != len(bidLadderLevels) {
+		t.Errorf("got %d series after GPU model is gone, want %d", n, len(bidLadderLevels))
+	}
+	top := bidLadderLevels[len(bidLadderLevels)-1]
+	labels := prometheus.Labels{"gpu_name": "RTX 4090", "bid": strconv.FormatFloat(top, 'f', -1, 64)}
+	if got := testutil.ToFloat64(e.bid_ladder_gpu_count.With(labels)); got != 2 {
+		t.Errorf("got %v GPUs at or below %v, want 2", got, top)
+	}
+}
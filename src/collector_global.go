@@ -1,6 +1,9 @@
 package main
 
 import (
+	"slices"
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -11,6 +14,19 @@ type VastAiGlobalCollector struct {
 	gpu_vram_gigabytes *prometheus.GaugeVec
 	gpu_teraflops      *prometheus.GaugeVec
 	gpu_dlperf_score   *prometheus.GaugeVec
+
+	isp_gpu_count    *TrackedGaugeVec
+	isp_gpu_share    *TrackedGaugeVec
+	isp_tflops_share *TrackedGaugeVec
+	isp_hhi          *TrackedGaugeVec
+
+	hosts_top_share          *TrackedGaugeVec
+	hosts_size_count         *prometheus.GaugeVec
+	hosts_size_gpu_count     *prometheus.GaugeVec
+	hosts_rented_range_count *prometheus.GaugeVec
+	hosts_mean_rented_ratio  prometheus.Gauge
+	hosts_new                prometheus.Gauge
+	hosts_gone               prometheus.Gauge
 }
 
 func newVastAiGlobalCollector() *VastAiGlobalCollector {
@@ -35,6 +51,63 @@ func newVastAiGlobalCollector() *VastAiGlobalCollector {
 			Name:      "gpu_dlperf_score",
 			Help:      "DLPerf score of the GPU model",
 		}, []string{"gpu_name"}),
+
+		isp_gpu_count: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "isp_gpu_count",
+			Help:      "Number of GPUs hosted per ISP, ASN or organization (top 20, the rest is summed as \"other\"; ASN is \"unknown\" unless the geolocation provider returns it)",
+		}, []string{"type", "name"}),
+		isp_gpu_share: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "isp_gpu_share",
+			Help:      "Share of GPUs hosted per ISP, ASN or organization, 0 to 1",
+		}, []string{"type", "name"}),
+		isp_tflops_share: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "isp_tflops_share",
+			Help:      "Share of TFLOPS hosted per ISP, ASN or organization, 0 to 1",
+		}, []string{"type", "name"}),
+		isp_hhi: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "isp_hhi",
+			Help:      "Herfindahl-Hirschman index of ISP, ASN or organization concentration, 0 to 10000",
+		}, []string{"type", "basis"}),
+
+		hosts_top_share: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_top_share",
+			Help:      "Share of GPUs of the model held by the largest N hosts, 0 to 1",
+		}, []string{"gpu_name", "top"}),
+		hosts_size_count: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_size_count",
+			Help:      "Number of hosts by range of GPU count per host",
+		}, []string{"size"}),
+		hosts_size_gpu_count: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_size_gpu_count",
+			Help:      "Number of GPUs on hosts by range of GPU count per host",
+		}, []string{"size"}),
+		hosts_rented_range_count: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_rented_range_count",
+			Help:      "Number of hosts by range of rented fraction of their GPUs",
+		}, []string{"range"}),
+		hosts_mean_rented_ratio: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_mean_rented_ratio",
+			Help:      "Mean over hosts of rented fraction of their GPUs, 0 to 1",
+		}),
+		hosts_new: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_new",
+			Help:      "Number of hosts which appeared since the previous update",
+		}),
+		hosts_gone: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hosts_gone",
+			Help:      "Number of hosts which disappeared since the previous update",
+		}),
 	}
 }
 
@@ -45,6 +118,19 @@ func (e *VastAiGlobalCollector) Describe(ch chan<- *prometheus.Desc) {
 	e.gpu_vram_gigabytes.Describe(ch)
 	e.gpu_teraflops.Describe(ch)
 	e.gpu_dlperf_score.Describe(ch)
+
+	e.isp_gpu_count.Describe(ch)
+	e.isp_gpu_share.Describe(ch)
+	e.isp_tflops_share.Describe(ch)
+	e.isp_hhi.Describe(ch)
+
+	e.hosts_top_share.Describe(ch)
+	e.hosts_size_count.Describe(ch)
+	e.hosts_size_gpu_count.Describe(ch)
+	e.hosts_rented_range_count.Describe(ch)
+	e.hosts_mean_rented_ratio.Describe(ch)
+	e.hosts_new.Describe(ch)
+	e.hosts_gone.Describe(ch)
 }
 
 func (e *VastAiGlobalCollector) Collect(ch chan<- prometheus.Metric) {
@@ -54,6 +140,19 @@ func (e *VastAiGlobalCollector) Collect(ch chan<- prometheus.Metric) {
 	e.gpu_vram_gigabytes.Collect(ch)
 	e.gpu_teraflops.Collect(ch)
 	e.gpu_dlperf_score.Collect(ch)
+
+	e.isp_gpu_count.Collect(ch)
+	e.isp_gpu_share.Collect(ch)
+	e.isp_tflops_share.Collect(ch)
+	e.isp_hhi.Collect(ch)
+
+	e.hosts_top_share.Collect(ch)
+	e.hosts_size_count.Collect(ch)
+	e.hosts_size_gpu_count.Collect(ch)
+	e.hosts_rented_range_count.Collect(ch)
+	e.hosts_mean_rented_ratio.Collect(ch)
+	e.hosts_new.Collect(ch)
+	e.hosts_gone.Collect(ch)
 }
 
 func (e *VastAiGlobalCollector) UpdateFrom(offerCache *OfferCacheSnapshot) {
@@ -72,4 +171,67 @@ func (e *VastAiGlobalCollector) UpdateFrom(offerCache *OfferCacheSnapshot) {
 			e.gpu_teraflops.With(labels).Set(info.Tflops)
 		}
 	}
+
+	if offerCache.ispStats != nil {
+		e.UpdateIspStats(*offerCache.ispStats)
+	}
+	if offerCache.hostsStats != nil {
+		e.UpdateHostsStats(offerCache.hostsStats)
+	}
+}
+
+func (e *VastAiGlobalCollector) UpdateHostsStats(stats *HostsStats) {
+	for _, model := range stats.GpuModels {
+		for _, s := range model.TopShares {
+			e.hosts_top_share.Set(prometheus.Labels{"gpu_name": model.Name, "top": strconv.Itoa(s.Top)}, s.Share)
+		}
+	}
+	e.hosts_top_share.Sweep()
+	for _, r := range stats.SizeDistribution {
+		e.hosts_size_count.With(prometheus.Labels{"size": r.Range}).Set(float64(r.Hosts))
+		e.hosts_size_gpu_count.With(prometheus.Labels{"size": r.Range}).Set(float64(r.Gpus))
+	}
+	for _, r := range stats.RentedDistribution {
+		e.hosts_rented_range_count.With(prometheus.Labels{"range": r.Range}).Set(float64(r.Hosts))
+	}
+	e.hosts_mean_rented_ratio.Set(stats.MeanRentedFraction)
+	if stats.Turnover.Known {
+		e.hosts_new.Set(float64(stats.Turnover.New))
+		e.hosts_gone.Set(float64(stats.Turnover.Gone))
+	}
+}
+
+func (e *VastAiGlobalCollector) UpdateIspStats(stats IspStats) {
+	// providers come and go, series of the ones which disappeared are deleted by Sweep
+	defer func() {
+		e.isp_gpu_count.Sweep()
+		e.isp_gpu_share.Sweep()
+		e.isp_tflops_share.Sweep()
+		e.isp_hhi.Sweep()
+	}()
+
+	if stats.LocatedGpus == 0 {
+		return
+	}
+	for _, g := range []struct {
+		name  string
+		group IspStatsGroup
+	}{
+		{"isp", stats.Isp},
+		{"asn", stats.Asn},
+		{"organization", stats.Organization},
+	} {
+		top, other := g.group.topWithOther(ispMetricsTopN)
+		if other != nil {
+			top = append(slices.Clone(top), *other)
+		}
+		for _, p := range top {
+			labels := prometheus.Labels{"type": g.name, "name": p.Name}
+			e.isp_gpu_count.Set(labels, float64(p.Gpus))
+			e.isp_gpu_share.Set(labels, p.GpuShare)
+			e.isp_tflops_share.Set(labels, p.TflopsShare)
+		}
+		e.isp_hhi.Set(prometheus.Labels{"type": g.name, "basis": "gpus"}, g.group.HhiGpus)
+		e.isp_hhi.Set(prometheus.Labels{"type": g.name, "basis": "tflops"}, g.group.HhiTflops)
+	}
 }
@@ -13,12 +13,17 @@ type VastAiPriceStatsCollectorV2 struct {
 	v2_ondemand_price_p90_dollars    *prometheus.GaugeVec
 
 	v2_gpu_count *prometheus.GaugeVec
+
+	v2_host_class_gpu_count                     *TrackedGaugeVec
+	v2_host_class_ondemand_price_median_dollars *TrackedGaugeVec
 }
 
 func newVastAiPriceStatsCollectorV2() VastAiPriceStatsCollectorV2 {
 	namespace := "vastai"
 
 	labelNames := []string{"gpu_name", "verified", "rented", "datacenter", "gpu_count_range"}
+	// host class is a separate breakdown, so that it does not multiply the series above
+	hostClassLabelNames := []string{"gpu_name", "host_class", "rented"}
 
 	return VastAiPriceStatsCollectorV2{
 		v2_ondemand_price_median_dollars: prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -42,6 +47,17 @@ func newVastAiPriceStatsCollectorV2() VastAiPriceStatsCollectorV2 {
 			Name:      "v2_gpu_count",
 			Help:      "Number of GPUs offered on site (categorized)",
 		}, labelNames),
+
+		v2_host_class_gpu_count: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "v2_host_class_gpu_count",
+			Help:      "Number of GPUs offered on site per class of host (datacenter, colo, prosumer, hobbyist)",
+		}, hostClassLabelNames),
+		v2_host_class_ondemand_price_median_dollars: newTrackedGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "v2_host_class_ondemand_price_median_dollars",
+			Help:      "Median on-demand price per GPU model and class of host",
+		}, hostClassLabelNames),
 	}
 }
 
@@ -51,6 +67,9 @@ func (e *VastAiPriceStatsCollectorV2) Describe(ch chan<- *prometheus.Desc) {
 	e.v2_ondemand_price_p90_dollars.Describe(ch)
 
 	e.v2_gpu_count.Describe(ch)
+
+	e.v2_host_class_gpu_count.Describe(ch)
+	e.v2_host_class_ondemand_price_median_dollars.Describe(ch)
 }
 
 func (e *VastAiPriceStatsCollectorV2) Collect(ch chan<- prometheus.Metric) {
@@ -59,6 +78,9 @@ func (e *VastAiPriceStatsCollectorV2) Collect(ch chan<- prometheus.Metric) {
 	e.v2_ondemand_price_p90_dollars.Collect(ch)
 
 	e.v2_gpu_count.Collect(ch)
+
+	e.v2_host_class_gpu_count.Collect(ch)
+	e.v2_host_class_ondemand_price_median_dollars.Collect(ch)
 }
 
 func (e *VastAiPriceStatsCollectorV2) UpdateFrom(offerCache *OfferCacheSnapshot, gpuNames []string) {
@@ -93,7 +115,15 @@ func (e *VastAiPriceStatsCollectorV2) UpdateFrom(offerCache *OfferCacheSnapshot,
 	isMyGpu["RTX 4090"] = true
 	isMyGpu["RTX 5090"] = true
 
-	for _, entry := range offerCache.machines.categorizedStats() {
+	categories := offerCache.machines.categorizedStatsBy(func(m *VastAiMachineOffer) categoryKey {
+		return categoryKey{
+			gpuName:       m.GpuName,
+			verified:      m.Verified,
+			datacenter:    m.Datacenter,
+			gpuCountRange: gpuCountRange(m.NumGpus),
+		}
+	})
+	for _, entry := range categories {
 		gpuName := entry.GpuName
 		if filterByGpuName && !isMyGpu[gpuName] {
 			continue
@@ -106,19 +136,44 @@ func (e *VastAiPriceStatsCollectorV2) UpdateFrom(offerCache *OfferCacheSnapshot,
 			"gpu_count_range": string(entry.GpuCountRange),
 		}
 
-		for _, r := range []struct {
-			label string
-			stats MachineStats
-		}{
-			{"yes", entry.Stats.Rented},
-			{"no", entry.Stats.Available},
-			{"any", entry.Stats.All},
-		} {
+		for _, r := range entry.Stats.byRented() {
 			labels := maps.Clone(baseLabels)
 			labels["rented"] = r.label
 			updateMetrics(labels, r.stats)
 		}
 	}
+
+	hostClasses := offerCache.machines.categorizedStatsBy(func(m *VastAiMachineOffer) categoryKey {
+		return categoryKey{gpuName: m.GpuName, hostClass: m.HostClass}
+	})
+	for _, entry := range hostClasses {
+		if filterByGpuName && !isMyGpu[entry.GpuName] {
+			continue
+		}
+		for _, r := range entry.Stats.byRented() {
+			labels := prometheus.Labels{"gpu_name": entry.GpuName, "host_class": string(entry.HostClass), "rented": r.label}
+			e.v2_host_class_gpu_count.Set(labels, float64(r.stats.Count))
+			if !math.IsNaN(r.stats.Median) {
+				e.v2_host_class_ondemand_price_median_dollars.Set(labels, r.stats.Median/100)
+			}
+		}
+	}
+	e.v2_host_class_gpu_count.Sweep()
+	e.v2_host_class_ondemand_price_median_dollars.Sweep()
+}
+
+type rentedStats struct {
+	label string
+	stats MachineStats
+}
+
+// byRented returns stats with values of the "rented" label
+func (s CategorizedStats_CategoryStats) byRented() []rentedStats {
+	return []rentedStats{
+		{"yes", s.Rented},
+		{"no", s.Available},
+		{"any", s.All},
+	}
 }
 
 func boolToYesNo(value bool) string {
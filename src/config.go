@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"go.yaml.in/yaml/v2"
+)
+
+const configCheckInterval = 10 * time.Second
+
+// settings which can be changed without restart; others are only applied on start
+var reloadableSettings = []string{
+	"update-interval",
+	"account-update-interval",
+	"invoices-update-interval",
+	"user-agent",
+	"no-geolocation",
+	"geo-negative-ttl",
+	"geo-daily-budget",
+	"host-map-cache-size",
+	"reliability-drop-threshold",
+	"log-level",
+}
+
+// settings of the Vast.ai account, only accepted in "account" section of the config;
+// the exporter serves one account, so they apply to the account given by its key
+var accountSettings = []string{
+	"key",
+	"account-update-interval",
+	"invoices-update-interval",
+	"reliability-drop-threshold",
+}
+
+// settings which make no sense in the config file
+var nonConfigSettings = []string{
+	"config",
+	"download-test-data",
+	"test-parsing",
+	"help",
+	"version",
+}
+
+var (
+	configMu sync.Mutex
+	// values from the last loaded config, to find out what was changed
+	loadedConfig map[string]string
+	// flags given explicitly on the command line, they take precedence over the config
+	commandLineFlags map[string]bool
+)
+
+// flagsSetOnCommandLine returns names of the flags present in args
+func flagsSetOnCommandLine(args []string) map[string]bool {
+	result := make(map[string]bool)
+	ctx, err := kingpin.CommandLine.ParseContext(args)
+	if ctx == nil || err != nil {
+		return result
+	}
+	for _, element := range ctx.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok {
+			result[flag.Model().Name] = true
+		}
+	}
+	return result
+}
+
+// loadConfig reads YAML config, keys are names of command line flags (e.g. "update-interval");
+// flags given on the command line are not overridden. The whole file is validated before
+// anything is applied, on error the previous settings are kept
+func loadConfig(path string, initial bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	settings, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	// validate everything before applying anything
+	for name := range settings {
+		if kingpin.CommandLine.GetFlag(name) == nil || slices.Contains(nonConfigSettings, name) {
+			return fmt.Errorf("%s: unknown setting: %s", path, name)
+		}
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	// flag values before this load, restored if any value is invalid
+	previous := make(map[string]string)
+	restore := func() {
+		for name, value := range previous {
+			_ = kingpin.CommandLine.GetFlag(name).Model().Value.Set(value)
+		}
+	}
+	var applied []string
+	for _, name := range names {
+		value := settings[name]
+		if prev, found := loadedConfig[name]; found && prev == value {
+			continue
+		}
+		if commandLineFlags[name] {
+			slog.Warn("Setting is given on the command line, ignoring value from config", "setting", name, "path", path)
+			continue
+		}
+		if !initial && !slices.Contains(reloadableSettings, name) {
+			slog.Warn("Setting was changed, restart to apply", "setting", name, "path", path)
+			continue
+		}
+		flag := kingpin.CommandLine.GetFlag(name).Model()
+		previous[name] = flag.Value.String()
+		if err := flag.Value.Set(value); err != nil {
+			restore()
+			return fmt.Errorf("%s: invalid %s: %w", path, name, err)
+		}
+		applied = append(applied, name)
+	}
+	newSettings, err := readSettings()
+	if err != nil {
+		restore()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if !initial {
+		currentSettings.Store(newSettings)
+		for _, name := range applied {
+			slog.Info("Applied setting", "setting", name, "path", path)
+		}
+		if len(applied) > 0 {
+			applyReloadedSettings()
+		}
+	}
+	// settings removed from the config keep their current values
+	loadedConfig = settings
+	return nil
+}
+
+// parseConfig flattens the config into flag values; lists are joined with commas
+func parseConfig(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for key, value := range raw {
+		if key == "account" {
+			account, ok := value.(map[any]any)
+			if !ok {
+				return nil, fmt.Errorf("account must be a map")
+			}
+			for k, v := range account {
+				name := fmt.Sprint(k)
+				if !slices.Contains(accountSettings, name) {
+					return nil, fmt.Errorf("unknown account setting: %s", name)
+				}
+				result[name] = configValue(v)
+			}
+			continue
+		}
+		if slices.Contains(accountSettings, key) {
+			return nil, fmt.Errorf("%s is a setting of the account, put it in account section", key)
+		}
+		result[key] = configValue(value)
+	}
+	return result, nil
+}
+
+func configValue(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// applyReloadedSettings pushes changed settings to components which cache them
+func applyReloadedSettings() {
+	if geoCache != nil {
+		geoCache.setSkipNets(parseSkipNets(*noGeoLocation))
+	}
+	if err := applyLogLevel(); err != nil {
+		slog.Error("Could not apply log level", "err", err)
+	}
+}
+
+// watchConfig reloads the config when it changes or on SIGHUP
+func watchConfig(path string) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		slog.Error("Could not watch config", "err", err)
+		return
+	}
+	mtime := stat.ModTime()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(configCheckInterval)
+	for {
+		select {
+		case <-hup:
+			slog.Info("Got SIGHUP, reloading config", "path", path)
+		case <-ticker.C:
+			stat, err := os.Stat(path)
+			if err != nil || stat.ModTime().Equal(mtime) {
+				continue
+			}
+			mtime = stat.ModTime()
+			slog.Info("Config changed, reloading", "path", path)
+		}
+		if err := loadConfig(path, false); err != nil {
+			// keep running with the previous settings
+			slog.Error("Could not reload config", "err", err)
+		}
+	}
+}
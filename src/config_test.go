@@ -0,0 +1,178 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "flat",
+			yaml: "update-interval: 30s\ngeo-daily-budget: 100\n",
+			want: map[string]string{"update-interval": "30s", "geo-daily-budget": "100"},
+		},
+		{
+			name: "list",
+			yaml: "no-geolocation:\n  - 10.0.0.0/8\n  - 192.168.0.0/16\n",
+			want: map[string]string{"no-geolocation": "10.0.0.0/8,192.168.0.0/16"},
+		},
+		{
+			name: "account",
+			yaml: "account:\n  key: KEY\n  reliability-drop-threshold: 0.02\n",
+			want: map[string]string{"key": "KEY", "reliability-drop-threshold": "0.02"},
+		},
+		{
+			name: "empty value",
+			yaml: "user-agent:\n",
+			want: map[string]string{"user-agent": ""},
+		},
+		{name: "unknown account setting", yaml: "account:\n  master-url: x\n", wantErr: true},
+		{name: "account setting outside of account", yaml: "key: KEY\n", wantErr: true},
+		{name: "account is not a map", yaml: "account: KEY\n", wantErr: true},
+		{name: "invalid yaml", yaml: "update-interval: [\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// resetFlags applies flag defaults, as if started without args
+func resetFlags(t *testing.T) {
+	t.Helper()
+	if _, err := kingpin.CommandLine.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	loadedConfig = nil
+	commandLineFlags = nil
+	currentSettings.Store(nil)
+	t.Cleanup(func() {
+		loadedConfig = nil
+		commandLineFlags = nil
+		currentSettings.Store(nil)
+	})
+}
+
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigInvalidAppliesNothing(t *testing.T) {
+	resetFlags(t)
+	if err := loadConfig(writeConfig(t, "update-interval: 30s\n"), true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []string{
+		"update-interval: 10s\ngeo-daily-budget: -1\n",
+		"update-interval: 10s\nhost-map-cache-size: -5\n",
+		"update-interval: 10s\ngeo-negative-ttl: -1h\n",
+		"update-interval: -10s\n",
+		"update-interval: 10s\naccount:\n  account-update-interval: 0s\n",
+		"update-interval: 10s\nlog-level: verbose\n",
+		"update-interval: 10s\nunknown-setting: 1\n",
+	}
+	for _, yaml := range tests {
+		if err := loadConfig(writeConfig(t, yaml), false); err == nil {
+			t.Errorf("%q: got no error", yaml)
+		}
+		if *updateInterval != 30*time.Second {
+			t.Errorf("%q: update-interval was applied: %v", yaml, *updateInterval)
+		}
+		if *geoDailyBudget != 0 || *hostMapCacheSize != 64 || *geoNegativeTtl != 24*time.Hour || *logLevelName != "info" {
+			t.Errorf("%q: invalid value was applied", yaml)
+		}
+	}
+}
+
+func TestLoadConfigReload(t *testing.T) {
+	resetFlags(t)
+	if err := loadConfig(writeConfig(t, "update-interval: 30s\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(writeConfig(t, "update-interval: 0s\ngeo-daily-budget: 100\n"), false); err != nil {
+		t.Fatal(err)
+	}
+	s := settings()
+	// 0 means the default, which must be resolved on reload as well
+	if s.updateInterval != time.Minute || s.geoDailyBudget != 100 {
+		t.Errorf("got %+v", s)
+	}
+}
+
+func TestLoadConfigCommandLinePrecedence(t *testing.T) {
+	resetFlags(t)
+	if _, err := kingpin.CommandLine.Parse([]string{"--geo-daily-budget=5"}); err != nil {
+		t.Fatal(err)
+	}
+	commandLineFlags = flagsSetOnCommandLine([]string{"--geo-daily-budget=5"})
+	if err := loadConfig(writeConfig(t, "geo-daily-budget: 100\nhost-map-cache-size: 10\n"), true); err != nil {
+		t.Fatal(err)
+	}
+	if *geoDailyBudget != 5 || *hostMapCacheSize != 10 {
+		t.Errorf("got geo-daily-budget %d, host-map-cache-size %d, want 5, 10", *geoDailyBudget, *hostMapCacheSize)
+	}
+}
+
+func TestLoadConfigAccount(t *testing.T) {
+	resetFlags(t)
+	prevKey := *apiKey
+	t.Cleanup(func() { *apiKey = prevKey })
+
+	config := "update-interval: 30s\naccount:\n  key: KEY\n  account-update-interval: 30s\n  invoices-update-interval: 1h\n"
+	if err := loadConfig(writeConfig(t, config), true); err != nil {
+		t.Fatal(err)
+	}
+	if *apiKey != "KEY" || *accountUpdateInterval != 30*time.Second || *invoicesUpdateInterval != time.Hour {
+		t.Errorf("got key %q, intervals %v, %v", *apiKey, *accountUpdateInterval, *invoicesUpdateInterval)
+	}
+
+	// intervals are applied on reload, the key needs a restart
+	config = "update-interval: 30s\naccount:\n  key: OTHER\n  account-update-interval: 1m\n  invoices-update-interval: 1h\n  reliability-drop-threshold: 0.1\n"
+	if err := loadConfig(writeConfig(t, config), false); err != nil {
+		t.Fatal(err)
+	}
+	if *apiKey != "KEY" {
+		t.Errorf("key was changed without restart: %q", *apiKey)
+	}
+	s := settings()
+	if s.accountUpdateInterval != time.Minute || s.invoicesUpdateInterval != time.Hour || s.reliabilityDropThreshold != 0.1 {
+		t.Errorf("got %+v", s)
+	}
+
+	if err := loadConfig(writeConfig(t, "account-update-interval: 5m\n"), false); err == nil {
+		t.Error("got no error for account setting outside of account section")
+	}
+	if settings().accountUpdateInterval != time.Minute {
+		t.Errorf("got account-update-interval %v after invalid config", settings().accountUpdateInterval)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// kinds of inconsistencies in the offer list
+const (
+	anomalyMissingFields    = "missing_fields"    // offer could not be decoded
+	anomalyMissingId        = "missing_id"        // offer without id, it is skipped
+	anomalyDuplicateOffer   = "duplicate_offer"   // same offer id listed more than once
+	anomalyDuplicateMachine = "duplicate_machine" // more than one chunk with frac=1.0
+	anomalyNoWholeMachine   = "no_whole_machine"  // no chunk with frac=1.0, machine is skipped
+	anomalyWeirdChunks      = "weird_chunks"      // smallest chunks don't sum up to the machine size
+)
+
+var anomalyKinds = []string{
+	anomalyMissingFields,
+	anomalyMissingId,
+	anomalyDuplicateOffer,
+	anomalyDuplicateMachine,
+	anomalyNoWholeMachine,
+	anomalyWeirdChunks,
+}
+
+// max number of anomalies listed in /data-quality, counts include all of them
+const dataQualityListLimit = 1000
+
+type OfferAnomaly struct {
+	Kind       string    `json:"kind"`
+	MachineId  int       `json:"machine_id"`
+	OfferIds   []int     `json:"offer_ids,omitempty"`
+	ChunkSizes []int     `json:"chunk_sizes,omitempty"`
+	FirstSeen  time.Time `json:"first_seen"`
+}
+
+func (a *OfferAnomaly) key() string {
+	return a.Kind + "/" + strconv.Itoa(a.MachineId) + "/" + fmt.Sprint(a.OfferIds)
+}
+
+// OfferAnomalies collects anomalies found while processing one snapshot of offers
+type OfferAnomalies struct {
+	items []OfferAnomaly
+}
+
+func (anomalies *OfferAnomalies) add(kind string, machineId int, offerIds []int, chunkSizes []int) {
+	if anomalies == nil {
+		return
+	}
+	anomalies.items = append(anomalies.items, OfferAnomaly{
+		Kind:       kind,
+		MachineId:  machineId,
+		OfferIds:   offerIds,
+		ChunkSizes: chunkSizes,
+	})
+}
+
+type DataQualityResponse struct {
+	Url       string         `json:"url"`
+	Timestamp time.Time      `json:"timestamp"`
+	Notes     []string       `json:"notes"`
+	Counts    map[string]int `json:"counts"`
+	Anomalies []OfferAnomaly `json:"anomalies"`
+}
+
+// remembers when each anomaly was first seen, anomalies which disappeared are forgotten
+type dataQualityTracker struct {
+	mu        sync.Mutex
+	firstSeen map[string]time.Time
+}
+
+var dataQuality = dataQualityTracker{firstSeen: make(map[string]time.Time)}
+
+func (t *dataQualityTracker) update(anomalies *OfferAnomalies, ts time.Time) *CachedResponse {
+	defer timeStage("json_data_quality")()
+
+	counts := make(map[string]int, len(anomalyKinds))
+	for _, kind := range anomalyKinds {
+		counts[kind] = 0
+	}
+
+	t.mu.Lock()
+	firstSeen := make(map[string]time.Time, len(anomalies.items))
+	for i := range anomalies.items {
+		a := &anomalies.items[i]
+		key := a.key()
+		seen, found := t.firstSeen[key]
+		if !found {
+			seen = ts
+		}
+		firstSeen[key] = seen
+		a.FirstSeen = seen.UTC()
+		counts[a.Kind]++
+	}
+	t.firstSeen = firstSeen
+	t.mu.Unlock()
+
+	if metrics != nil {
+		metrics.UpdateOfferAnomalies(counts)
+	}
+
+	items := slices.Clone(anomalies.items)
+	slices.SortFunc(items, func(a, b OfferAnomaly) int {
+		if c := cmp.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.MachineId, b.MachineId); c != 0 {
+			return c
+		}
+		// a machine may have several anomalies of the same kind, keep their order stable between updates
+		if c := slices.Compare(a.OfferIds, b.OfferIds); c != 0 {
+			return c
+		}
+		return slices.Compare(a.ChunkSizes, b.ChunkSizes)
+	})
+	if items == nil {
+		items = []OfferAnomaly{}
+	}
+
+	j, err := json.MarshalIndent(DataQualityResponse{
+		Url:       "/data-quality",
+		Timestamp: ts.UTC(),
+		Notes: []string{
+			"Inconsistencies of the Vast.ai offer list found in the current snapshot, sorted by kind and machine id.",
+			"Kinds: missing_fields (offer could not be decoded), missing_id (offer without id, it is skipped), " +
+				"duplicate_offer (offer id listed more than once), " +
+				"duplicate_machine (more than one chunk with frac=1.0), no_whole_machine (no chunk with frac=1.0, machine is skipped), " +
+				"weird_chunks (smallest chunks don't sum up to the machine size).",
+			"First seen is the time of the first snapshot with the same anomaly since the exporter was started.",
+			"The list is limited to " + strconv.Itoa(dataQualityListLimit) + " items, counts include all of them.",
+		},
+		Counts:    counts,
+		Anomalies: items[:min(len(items), dataQualityListLimit)],
+	}, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", "/data-quality", "err", err)
+		return buildCachedResponse(ts, "/data-quality", nil)
+	}
+	return buildCachedResponse(ts, "/data-quality", j)
+}
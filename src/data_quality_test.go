@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+func rawTestOffer(id, machineId int, score float64) VastAiRawOffer {
+	return VastAiRawOffer{
+		"id":         float64(id),
+		"machine_id": float64(machineId),
+		"host_id":    float64(1),
+		"gpu_name":   "RTX 4090",
+		"num_gpus":   float64(1),
+		"dph_base":   0.3,
+		"rentable":   true,
+		"gpu_frac":   1.0,
+		"score":      score,
+	}
+}
+
+func TestDecodeOfferAnomalies(t *testing.T) {
+	incomplete := rawTestOffer(4, 20, 0)
+	delete(incomplete, "gpu_name")
+	raw := VastAiRawOffers{
+		rawTestOffer(1, 10, 1),
+		rawTestOffer(1, 10, 2),
+		rawTestOffer(0, 10, 1),
+		rawTestOffer(2, 11, 1),
+		incomplete,
+	}
+
+	anomalies := &OfferAnomalies{}
+	offers := raw.decode(anomalies)
+	if len(offers) != 2 {
+		t.Fatalf("got %d offers, want 2", len(offers))
+	}
+	// the duplicate with the highest score is kept
+	for _, offer := range offers {
+		if offer.Id == 1 && offer.Score != 2 {
+			t.Errorf("got score %v of offer 1, want 2", offer.Score)
+		}
+	}
+
+	want := []OfferAnomaly{
+		{Kind: anomalyMissingFields, MachineId: 20, OfferIds: []int{4}},
+		{Kind: anomalyMissingId, MachineId: 10},
+		{Kind: anomalyDuplicateOffer, MachineId: 10, OfferIds: []int{1}},
+	}
+	if len(anomalies.items) != len(want) {
+		t.Fatalf("got %+v, want %+v", anomalies.items, want)
+	}
+	for i, a := range anomalies.items {
+		if a.Kind != want[i].Kind || a.MachineId != want[i].MachineId || !slices.Equal(a.OfferIds, want[i].OfferIds) {
+			t.Errorf("anomaly %d: got %+v, want %+v", i, a, want[i])
+		}
+	}
+}
+
+func TestDataQualityUpdate(t *testing.T) {
+	tracker := dataQualityTracker{firstSeen: make(map[string]time.Time)}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	parse := func(resp *CachedResponse) DataQualityResponse {
+		t.Helper()
+		var result DataQualityResponse
+		if err := json.Unmarshal(resp.raw, &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first := &OfferAnomalies{}
+	first.add(anomalyWeirdChunks, 5, []int{3, 4}, []int{1, 2})
+	first.add(anomalyDuplicateMachine, 5, []int{2, 3}, nil)
+	first.add(anomalyWeirdChunks, 5, []int{1, 2}, []int{1, 2})
+	parse(tracker.update(first, start))
+
+	second := &OfferAnomalies{}
+	second.add(anomalyWeirdChunks, 5, []int{3, 4}, []int{1, 2})
+	second.add(anomalyMissingId, 7, nil, nil)
+	second.add(anomalyWeirdChunks, 5, []int{1, 2}, []int{1, 2})
+	now := start.Add(time.Minute)
+	result := parse(tracker.update(second, now))
+
+	if result.Counts[anomalyWeirdChunks] != 2 || result.Counts[anomalyMissingId] != 1 || result.Counts[anomalyDuplicateMachine] != 0 {
+		t.Errorf("got counts %v", result.Counts)
+	}
+	if len(result.Counts) != len(anomalyKinds) {
+		t.Errorf("got %d kinds, want all %d", len(result.Counts), len(anomalyKinds))
+	}
+
+	// sorted by kind, machine and offer ids, anomalies seen before keep their first seen time
+	want := []struct {
+		kind      string
+		offerIds  []int
+		firstSeen time.Time
+	}{
+		{anomalyMissingId, nil, now},
+		{anomalyWeirdChunks, []int{1, 2}, start},
+		{anomalyWeirdChunks, []int{3, 4}, start},
+	}
+	if len(result.Anomalies) != len(want) {
+		t.Fatalf("got %+v", result.Anomalies)
+	}
+	for i, a := range result.Anomalies {
+		if a.Kind != want[i].kind || !slices.Equal(a.OfferIds, want[i].offerIds) || !a.FirstSeen.Equal(want[i].firstSeen) {
+			t.Errorf("anomaly %d: got %+v, want %+v", i, a, want[i])
+		}
+	}
+
+	// the duplicate machine disappeared and is forgotten
+	third := &OfferAnomalies{}
+	third.add(anomalyDuplicateMachine, 5, []int{2, 3}, nil)
+	result = parse(tracker.update(third, now.Add(time.Minute)))
+	if a := result.Anomalies[0]; !a.FirstSeen.Equal(now.Add(time.Minute)) {
+		t.Errorf("got first seen %v of a reappeared anomaly, want %v", a.FirstSeen, now.Add(time.Minute))
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"time"
+)
+
+type DebugSnapshotResponse struct {
+	Url                 string                           `json:"url"`
+	Timestamp           time.Time                        `json:"timestamp"`
+	Notes               []string                         `json:"notes"`
+	OffersTimestamp     time.Time                        `json:"offers_timestamp"`
+	OfferCount          int                              `json:"offer_count"`
+	MachineCount        int                              `json:"machine_count"`
+	Responses           []DebugResponseSize              `json:"responses"`
+	ResponsesBytes      int                              `json:"responses_bytes"`
+	ResponsesGzipped    int                              `json:"responses_gzipped_bytes"`
+	HostMapCacheEntries int                              `json:"host_map_cache_entries"`
+	MarshalerBuffers    map[string]DebugMarshalerBuffers `json:"marshaler_buffers"`
+	GeoCache            *DebugGeoCacheSize               `json:"geo_cache"`
+	Memory              DebugMemory                      `json:"memory"`
+}
+
+type DebugResponseSize struct {
+	Endpoint string `json:"endpoint"`
+	Bytes    int    `json:"bytes"`
+	Gzipped  int    `json:"gzipped_bytes"`
+}
+
+type DebugMarshalerBuffers struct {
+	Raw     int `json:"raw_cap_bytes"`
+	Gzip    int `json:"gzip_cap_bytes"`
+	Workers int `json:"workers_cap_bytes"`
+	Total   int `json:"total_cap_bytes"`
+}
+
+type DebugGeoCacheSize struct {
+	Entries    int `json:"entries"`
+	Negative   int `json:"negative"`
+	Pending    int `json:"pending"`
+	QueueDepth int `json:"queue_depth"`
+}
+
+type DebugMemory struct {
+	HeapAlloc   uint64    `json:"heap_alloc_bytes"`
+	HeapInuse   uint64    `json:"heap_inuse_bytes"`
+	HeapSys     uint64    `json:"heap_sys_bytes"`
+	HeapObjects uint64    `json:"heap_objects"`
+	NumGC       uint32    `json:"num_gc"`
+	LastGC      time.Time `json:"last_gc"`
+}
+
+// debugHandler serves pprof and /debug/snapshot on --debug-listen, separately from the main listener
+func debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJson(w, offerCache.Snapshot().debugInfo())
+	})
+	return mux
+}
+
+func (snap *OfferCacheSnapshot) debugInfo() DebugSnapshotResponse {
+	result := DebugSnapshotResponse{
+		Url:       "/debug/snapshot",
+		Timestamp: time.Now().UTC(),
+		Notes: []string{
+			"Sizes of pre-serialized responses, preallocated marshaler buffers and caches.",
+			"Machine count is 0 between updates if machines are not needed by account metrics.",
+			"Each marshaler has two raw and two gzip buffers, which are swapped on every update.",
+		},
+		OffersTimestamp: snap.ts.UTC(),
+		OfferCount:      snap.offerCount,
+		MachineCount:    len(snap.machines),
+		Responses:       make([]DebugResponseSize, 0, len(snap.responses)),
+		MarshalerBuffers: map[string]DebugMarshalerBuffers{
+			"offers":   offersMarshaler.debugInfo(),
+			"machines": machinesMarshaler.debugInfo(),
+		},
+	}
+
+	for endpoint, resp := range snap.responses {
+		if resp == nil {
+			continue
+		}
+		result.Responses = append(result.Responses, DebugResponseSize{
+			Endpoint: endpoint,
+			Bytes:    len(resp.raw),
+			Gzipped:  len(resp.gzipped),
+		})
+		result.ResponsesBytes += len(resp.raw)
+		result.ResponsesGzipped += len(resp.gzipped)
+	}
+	slices.SortFunc(result.Responses, func(a, b DebugResponseSize) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.Endpoint, b.Endpoint))
+	})
+
+	if snap.hostMap != nil {
+		result.HostMapCacheEntries = snap.hostMap.cache.Len()
+	}
+	if geoCache != nil {
+		result.GeoCache = geoCache.debugInfo()
+	}
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	result.Memory = DebugMemory{
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapSys:     m.HeapSys,
+		HeapObjects: m.HeapObjects,
+		NumGC:       m.NumGC,
+		LastGC:      time.Unix(0, int64(m.LastGC)).UTC(),
+	}
+	return result
+}
+
+func (s *Marshaler) debugInfo() DebugMarshalerBuffers {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := DebugMarshalerBuffers{
+		Raw:   s.rawBuf.Cap(),
+		Gzip:  s.gzipBuf.Cap(),
+		Total: s.bufCap(),
+	}
+	for _, buf := range s.workerBufs {
+		result.Workers += buf.Cap()
+	}
+	return result
+}
+
+func (cache *GeoCache) debugInfo() *DebugGeoCacheSize {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	result := &DebugGeoCacheSize{
+		Entries:    len(cache.Entries),
+		Pending:    len(cache.pending),
+		QueueDepth: cache.queueDepth(),
+	}
+	for _, entry := range cache.Entries {
+		if entry.Location == nil {
+			result.Negative++
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebugSnapshot(t *testing.T) {
+	ts := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	offerCache.mu.Lock()
+	prevCount, prevMachines, prevResponses, prevTs := offerCache.offerCount, offerCache.machines, offerCache.responses, offerCache.ts
+	offerCache.offerCount = 3
+	offerCache.machines = VastAiMachineOffers{{MachineId: 1}, {MachineId: 2}}
+	offerCache.responses = SerializedResponses{
+		"/offers":    {raw: make([]byte, 300), gzipped: make([]byte, 30)},
+		"/machines":  {raw: make([]byte, 200), gzipped: make([]byte, 20)},
+		"/gpu-stats": {raw: make([]byte, 200), gzipped: make([]byte, 10)},
+		"/hosts":     nil,
+	}
+	offerCache.ts = ts
+	offerCache.mu.Unlock()
+	t.Cleanup(func() {
+		offerCache.mu.Lock()
+		offerCache.offerCount, offerCache.machines, offerCache.responses, offerCache.ts = prevCount, prevMachines, prevResponses, prevTs
+		offerCache.mu.Unlock()
+	})
+
+	w := httptest.NewRecorder()
+	debugHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/snapshot", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("got %d, Cache-Control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	var got DebugSnapshotResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.OffersTimestamp.Equal(ts) || got.OfferCount != 3 || got.MachineCount != 2 {
+		t.Errorf("got timestamp %v, %d offers, %d machines", got.OffersTimestamp, got.OfferCount, got.MachineCount)
+	}
+	if got.ResponsesBytes != 700 || got.ResponsesGzipped != 60 {
+		t.Errorf("got %d bytes, %d gzipped, want 700, 60", got.ResponsesBytes, got.ResponsesGzipped)
+	}
+	// largest first, then by endpoint
+	want := []string{"/offers", "/gpu-stats", "/machines"}
+	if len(got.Responses) != len(want) {
+		t.Fatalf("got %+v, want %v", got.Responses, want)
+	}
+	for i, endpoint := range want {
+		if got.Responses[i].Endpoint != endpoint {
+			t.Errorf("response %d: got %s, want %s", i, got.Responses[i].Endpoint, endpoint)
+		}
+	}
+	for _, name := range []string{"offers", "machines"} {
+		b := got.MarshalerBuffers[name]
+		if b.Raw == 0 || b.Total != b.Raw+b.Gzip+b.Workers {
+			t.Errorf("got %s buffers %+v", name, b)
+		}
+	}
+	if got.Memory.HeapAlloc == 0 {
+		t.Errorf("got no memory stats")
+	}
+}
+
+// run with -race: buffers are inspected while another goroutine is marshaling
+func TestMarshalerDebugInfoWhileMarshaling(t *testing.T) {
+	m := NewMarshaler()
+	items := make([]int, 1000)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for range 20 {
+			if _, _, err := m.Marshal(OffersResponse{
+				Offers: &SerializableCollection{marshaler: m, count: len(items), get: func(i int) any { return items[i] }},
+			}); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	for range 20 {
+		if info := m.debugInfo(); info.Total < info.Raw {
+			t.Errorf("got %+v", info)
+		}
+		_ = m.BufCap()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/rand/v2"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type GeoLocation struct {
+	Country      string  `json:"country,omitempty"`
+	Location     string  `json:"location,omitempty"`
+	Lat          float64 `json:"lat,omitempty"`
+	Long         float64 `json:"long,omitempty"`
+	Accuracy     float64 `json:"accuracy,omitempty"` // in kilometers
+	ISP          string  `json:"isp,omitempty"`
+	ASN          uint32  `json:"asn,omitempty"`
+	Organization string  `json:"organization,omitempty"`
+	Domain       string  `json:"domain,omitempty"`
+}
+
+// entries with nil Location are negative, Reason tells why
+type GeoCacheEntry struct {
+	Expires  time.Time    `json:"Expires"`
+	Location *GeoLocation `json:"Location"`
+	Provider string       `json:"Provider,omitempty"`
+	Reason   string       `json:"Reason,omitempty"`
+}
+
+const (
+	// not found by any remote provider
+	geoReasonNotFound = "not_found"
+)
+
+type GeoCacheEntries map[string]GeoCacheEntry
+
+type GeoCache struct {
+	mu         sync.Mutex
+	Entries    GeoCacheEntries `json:"Entries"`
+	BudgetDay  string          `json:"BudgetDay,omitempty"`
+	BudgetUsed int             `json:"BudgetUsed,omitempty"`
+	providers  []GeoProvider
+	skipNets   []netip.Prefix
+	queue      chan geoRequest
+	pending    map[string]bool
+}
+
+const GeoLocationTTL = 7 * 24 * time.Hour
+const GeoLocationTTLVariance = 3 * time.Hour
+
+var geoCache *GeoCache
+
+func newGeoCache() *GeoCache {
+	return &GeoCache{
+		Entries: make(GeoCacheEntries),
+		queue:   make(chan geoRequest, geoQueueSize),
+		pending: make(map[string]bool),
+	}
+}
+
+func loadGeoCache(ctx context.Context) (*GeoCache, error) {
+	providers, err := makeGeoProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(providers) == 0 {
+		return nil, nil
+	}
+
+	cache := newGeoCache()
+	cache.providers = providers
+
+	j, err := os.ReadFile(geoCacheFile())
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	} else {
+		err := json.Unmarshal(j, &cache)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cache.removeExpired()
+	slog.Info("Loaded geolocation cache", "items", len(cache.Entries))
+
+	cache.setSkipNets(parseSkipNets(*noGeoLocation))
+
+	cache.startWorkers(ctx, *geoWorkers, *geoQps)
+
+	return cache, nil
+}
+
+func (cache *GeoCache) ipLocation(ip string, machineId int) *GeoLocation {
+	// invalid addresses never reach the cache or the providers
+	addr, err := parseIp(ip)
+	if err != nil {
+		warnLimited("geo_invalid_ip", "Invalid IP address", "ip", ip, "machine_id", machineId)
+		return nil
+	}
+	if !isGeolocatable(addr) {
+		warnLimited("geo_invalid_ip", "IP address from an invalid range", "ip", ip, "machine_id", machineId)
+		return nil
+	}
+	cache.mu.Lock()
+	skipNets := cache.skipNets
+	cache.mu.Unlock()
+	for _, prefix := range skipNets {
+		if prefix.Contains(addr) {
+			slog.Debug("Skipped geolocation", "ip", ip, "machine_id", machineId)
+			return nil
+		}
+	}
+
+	// query providers in priority order; local ones are cheap to query and are not cached
+	// so that updates to them are picked up immediately
+	key := geoCacheKey(addr)
+	checkedCache := false
+	for _, provider := range cache.providers {
+		if !provider.Cacheable() {
+			location, err := provider.Lookup(context.Background(), addr)
+			if err != nil {
+				slog.Error("Geolocation lookup failed", "provider", provider.Name(), "ip", ip, "err", err)
+				continue
+			}
+			if location != nil {
+				return location
+			}
+			continue
+		}
+		if checkedCache {
+			continue
+		}
+		checkedCache = true
+
+		// remote providers are queried in background, the result is picked up on one of the next cycles;
+		// a stale entry is used until it is refreshed
+		entry, found := cache.entry(key)
+		if !found || entry.expired() {
+			cache.enqueue(key, addr)
+		}
+		if found && entry.Location != nil {
+			return entry.Location
+		}
+	}
+	return nil
+}
+
+func parseSkipNets(s string) []netip.Prefix {
+	var result []netip.Prefix
+	for netStr := range strings.SplitSeq(s, ",") {
+		netStr = strings.TrimSpace(netStr)
+		if len(netStr) > 0 {
+			prefix, err := parseNetwork(netStr)
+			if err != nil {
+				slog.Error("Invalid --no-geolocation network", "err", err)
+			} else {
+				result = append(result, prefix)
+			}
+		}
+	}
+	return result
+}
+
+func (cache *GeoCache) setSkipNets(skipNets []netip.Prefix) {
+	cache.mu.Lock()
+	cache.skipNets = skipNets
+	cache.mu.Unlock()
+	if len(skipNets) > 0 {
+		slog.Info("Will skip geolocation", "networks", fmt.Sprint(skipNets))
+	}
+}
+
+func (cache *GeoCache) entry(key string) (GeoCacheEntry, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, found := cache.Entries[key]
+	return entry, found
+}
+
+// storeNegative remembers that the IP has no location; a known location is kept when its refresh
+// is not found, only its expiry is extended, so a host doesn't vanish from the map on a provider gap
+func (cache *GeoCache) storeNegative(key string, reason string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	expires := time.Now().Add(settings().geoNegativeTtl)
+	if entry, found := cache.Entries[key]; found && entry.Location != nil {
+		entry.Expires = expires
+		cache.Entries[key] = entry
+		return
+	}
+	cache.Entries[key] = GeoCacheEntry{
+		Expires: expires,
+		Reason:  reason,
+	}
+}
+
+func (cache *GeoCache) save() {
+	cache.mu.Lock()
+	cache.removeExpired()
+	j, _ := json.MarshalIndent(cache, "", "    ") //nolint:errchkjson // GeoCache contains only safe JSON types
+	cache.mu.Unlock()
+	err := writeFileAtomic(geoCacheFile(), j, 0600)
+	if err != nil {
+		slog.Error("Could not store geolocation cache", "err", err)
+	}
+}
+
+func (cache *GeoCache) removeExpired() {
+	cache.Entries = cache.Entries.removeExpired()
+}
+
+func (entry *GeoCacheEntry) expired() bool {
+	return entry.Expires.Before(time.Now())
+}
+
+func (entries GeoCacheEntries) removeExpired() GeoCacheEntries {
+	newEntries := make(GeoCacheEntries)
+	for ip, entry := range entries {
+		if !entry.expired() {
+			newEntries[ip] = entry
+		}
+	}
+	return newEntries
+}
+
+func makeExpireTime() time.Time {
+	// randomize so all entries do not expire at the same time
+	extra := time.Duration(rand.Int64N(int64(GeoLocationTTLVariance)))
+	return time.Now().Add(GeoLocationTTL).Add(extra)
+}
+
+func geoCacheFile() string {
+	return *stateDir + "/.vastai_geo_cache"
+}
+
+// IPv6 addresses are cached per /64 network, which is normally assigned to a single site
+func geoCacheKey(addr netip.Addr) string {
+	if addr.Is6() {
+		return netip.PrefixFrom(addr, 64).Masked().String()
+	}
+	return addr.String()
+}
+
+// parseIp accepts IPv6 addresses in brackets and drops zones, IPv4-mapped IPv6 addresses are converted to IPv4
+func parseIp(ip string) (netip.Addr, error) {
+	ip = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(ip), "["), "]")
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return addr, err
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+var (
+	cgnNet           = netip.MustParsePrefix("100.64.0.0/10")
+	ipv6DocNet       = netip.MustParsePrefix("2001:db8::/32")
+	ipv6TranslateNet = netip.MustParsePrefix("64:ff9b::/96")
+)
+
+func isGeolocatable(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	if addr.Is4() {
+		return !cgnNet.Contains(addr)
+	}
+	return !ipv6DocNet.Contains(addr) && !ipv6TranslateNet.Contains(addr)
+}
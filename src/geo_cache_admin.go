@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type GeoCacheListResponse struct {
+	Url        string               `json:"url"`
+	Timestamp  time.Time            `json:"timestamp"`
+	Count      int                  `json:"count"`
+	Negative   int                  `json:"negative_count"`
+	QueueDepth int                  `json:"queue_depth"`
+	BudgetDay  string               `json:"budget_day,omitempty"`
+	BudgetUsed int                  `json:"budget_used"`
+	Notes      []string             `json:"notes"`
+	Entries    []GeoCacheKeyedEntry `json:"entries"`
+}
+
+type GeoCacheKeyedEntry struct {
+	Key     string `json:"key"`
+	Pending bool   `json:"pending"`
+	GeoCacheEntry
+}
+
+type GeoCacheInvalidateResponse struct {
+	Removed int `json:"removed"`
+}
+
+// GET /geo-cache                  list all entries
+// GET /geo-cache?ip=IP            look up the entry of a single IP
+func geoCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if geoCache == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		entry := geoCache.lookupEntry(ip)
+		if entry == nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeJson(w, entry)
+		return
+	}
+	jsonHandler(w, r, geoCache.Response())
+}
+
+// invalidation is part of the admin API, so it always requires authentication:
+// DELETE /admin/geo-cache?ip=IP         invalidate the entry of a single IP
+// DELETE /admin/geo-cache?negative=1    invalidate all negative entries
+// DELETE /admin/geo-cache?all=1         invalidate all entries
+func geoCacheInvalidateHandler(w http.ResponseWriter, r *http.Request) {
+	if geoCache == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	var removed int
+	switch {
+	case query.Get("ip") != "":
+		removed = geoCache.invalidate(func(key string, _ GeoCacheEntry) bool {
+			return key == geoCacheKeyOf(query.Get("ip"))
+		})
+	case query.Get("negative") != "":
+		removed = geoCache.invalidate(func(_ string, entry GeoCacheEntry) bool {
+			return entry.Location == nil
+		})
+	case query.Get("all") != "":
+		removed = geoCache.invalidate(func(string, GeoCacheEntry) bool { return true })
+	default:
+		http.Error(w, `one of "ip", "negative" or "all" parameters is required`, http.StatusBadRequest)
+		return
+	}
+	slog.Info("Invalidated geolocation cache entries", "count", removed, "query", r.URL.RawQuery)
+	geoCache.save()
+	writeJson(w, GeoCacheInvalidateResponse{Removed: removed})
+}
+
+func (cache *GeoCache) Response() *CachedResponse {
+	now := time.Now()
+
+	cache.mu.Lock()
+	resp := GeoCacheListResponse{
+		Url:        "/geo-cache",
+		Timestamp:  now.UTC(),
+		QueueDepth: cache.queueDepth(),
+		BudgetDay:  cache.BudgetDay,
+		BudgetUsed: cache.BudgetUsed,
+		Notes: []string{
+			"Entries without location are negative, reason tells why.",
+			"IPv6 addresses are cached per /64 network.",
+		},
+		Entries: make([]GeoCacheKeyedEntry, 0, len(cache.Entries)),
+	}
+	for key, entry := range cache.Entries {
+		if entry.Location == nil {
+			resp.Negative++
+		}
+		resp.Entries = append(resp.Entries, GeoCacheKeyedEntry{Key: key, Pending: cache.pending[key], GeoCacheEntry: entry})
+	}
+	cache.mu.Unlock()
+
+	resp.Count = len(resp.Entries)
+	slices.SortFunc(resp.Entries, func(a, b GeoCacheKeyedEntry) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	j, err := json.MarshalIndent(resp, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize geolocation cache", "err", err)
+		return nil
+	}
+	return &CachedResponse{ts: now, etag: makeEtag(now, "/geo-cache"), raw: j}
+}
+
+// lookupEntry returns the entry of the IP by its cache key
+func (cache *GeoCache) lookupEntry(ip string) *GeoCacheKeyedEntry {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	key := geoCacheKeyOf(ip)
+	if entry, found := cache.Entries[key]; found {
+		return &GeoCacheKeyedEntry{Key: key, Pending: cache.pending[key], GeoCacheEntry: entry}
+	}
+	if cache.pending[key] {
+		return &GeoCacheKeyedEntry{Key: key, Pending: true}
+	}
+	return nil
+}
+
+func (cache *GeoCache) invalidate(match func(key string, entry GeoCacheEntry) bool) int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	removed := 0
+	for key, entry := range cache.Entries {
+		if match(key, entry) {
+			delete(cache.Entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func geoCacheKeyOf(ip string) string {
+	addr, err := parseIp(ip)
+	if err != nil {
+		return ip
+	}
+	return geoCacheKey(addr)
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	writeJsonStatus(w, http.StatusOK, v)
+}
+
+// writeJsonStatus marshals v before writing the status, so that a marshaling error can still be reported as 500
+func writeJsonStatus(w http.ResponseWriter, status int, v any) {
+	j, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(j)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreNegativeKeepsLocation(t *testing.T) {
+	currentSettings.Store(&runtimeSettings{geoNegativeTtl: time.Hour})
+	t.Cleanup(func() { currentSettings.Store(nil) })
+
+	location := &GeoLocation{Country: "DE"}
+	stale := time.Now().Add(-time.Minute)
+	cache := newGeoCache()
+	cache.Entries["1.2.3.4"] = GeoCacheEntry{Expires: stale, Location: location, Provider: "ip-api"}
+
+	cache.storeNegative("1.2.3.4", geoReasonNotFound)
+	entry := cache.Entries["1.2.3.4"]
+	if entry.Location != location || entry.Provider != "ip-api" || entry.Reason != "" {
+		t.Errorf("stale positive entry was replaced: %+v", entry)
+	}
+	if !entry.Expires.After(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("expiry was not extended: %v", entry.Expires)
+	}
+
+	cache.storeNegative("5.6.7.8", geoReasonNotFound)
+	entry = cache.Entries["5.6.7.8"]
+	if entry.Location != nil || entry.Reason != geoReasonNotFound {
+		t.Errorf("got %+v, want negative entry", entry)
+	}
+}
+
+func TestParseSkipNets(t *testing.T) {
+	got := parseSkipNets(" 10.0.0.0/8, 192.168.1.1 ,invalid,,2001:db8::1/32")
+	want := []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if nets := parseSkipNets(""); len(nets) != 0 {
+		t.Errorf("got %v for empty string", nets)
+	}
+}
+
+func TestGeoCacheKey(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.2.3.4", "1.2.3.4"},
+		{" 1.2.3.4 ", "1.2.3.4"},
+		{"::ffff:1.2.3.4", "1.2.3.4"},
+		// IPv6 addresses are cached per /64
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:ffff:ffff:ffff:ffff", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:3::1]", "2001:db8:1:3::/64"},
+		{"fe80::1%eth0", "fe80::/64"},
+		// invalid addresses are kept as is
+		{"not an ip", "not an ip"},
+	}
+	for _, tt := range tests {
+		if got := geoCacheKeyOf(tt.ip); got != tt.want {
+			t.Errorf("geoCacheKeyOf(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// returned by providers which are disabled at runtime, e.g. because of invalid credentials
+var errGeoProviderDisabled = errors.New("geolocation provider is disabled")
+
+type GeoProvider interface {
+	Name() string
+	// Cacheable tells if results should be stored in the persistent geolocation cache
+	Cacheable() bool
+	// Lookup returns nil location and nil error if the IP is not known to the provider
+	Lookup(ctx context.Context, ip netip.Addr) (*GeoLocation, error)
+}
+
+// builds the chain of configured providers in the order given by --geo-providers,
+// background reloading of the providers stops when ctx is cancelled
+func makeGeoProviders(ctx context.Context) ([]GeoProvider, error) {
+	var result []GeoProvider
+	for name := range strings.SplitSeq(*geoProviders, ",") {
+		var provider GeoProvider
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case "static":
+			if *geoStaticFile == "" {
+				continue
+			}
+			p, err := loadStaticGeoProvider(*geoStaticFile)
+			if err != nil {
+				return nil, err
+			}
+			provider = p
+		case "geoip-db":
+			if *geoIpDbFiles == "" {
+				continue
+			}
+			db, err := openGeoIpDb(*geoIpDbFiles)
+			if err != nil {
+				return nil, err
+			}
+			go db.watch(ctx)
+			provider = db
+		case "maxmind":
+			if *maxMindKey == "" {
+				continue
+			}
+			p, err := newMaxMindProvider(*maxMindKey)
+			if err != nil {
+				return nil, err
+			}
+			provider = p
+		case "http":
+			if *geoHttpUrl == "" {
+				continue
+			}
+			p, err := newHttpGeoProvider(*geoHttpUrl, *geoHttpFields)
+			if err != nil {
+				return nil, err
+			}
+			provider = p
+		default:
+			return nil, fmt.Errorf("unknown geolocation provider: %s", name)
+		}
+		slog.Info("Using geolocation provider", "provider", provider.Name())
+		result = append(result, provider)
+	}
+	return result, nil
+}
+
+// static overrides from a CSV or JSON file, e.g. for own datacenters
+type StaticGeoProvider struct {
+	entries []staticGeoEntry // longest prefixes first
+}
+
+type staticGeoEntry struct {
+	Network string `json:"network"`
+	GeoLocation
+	prefix netip.Prefix
+}
+
+func loadStaticGeoProvider(path string) (*StaticGeoProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []staticGeoEntry
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		err = json.Unmarshal(data, &entries)
+	} else {
+		entries, err = parseStaticGeoCsv(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i := range entries {
+		prefix, err := parseNetwork(entries[i].Network)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		entries[i].prefix = prefix
+	}
+	slices.SortStableFunc(entries, func(a, b staticGeoEntry) int {
+		return b.prefix.Bits() - a.prefix.Bits()
+	})
+
+	slog.Info("Loaded static geolocation entries", "count", len(entries), "path", path)
+	return &StaticGeoProvider{entries: entries}, nil
+}
+
+// CSV with a header line, columns: network,country,location,lat,long,accuracy,isp,asn,organization,domain (any order)
+func parseStaticGeoCsv(data []byte) ([]staticGeoEntry, error) {
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	var result []staticGeoEntry
+	for _, record := range records[1:] {
+		var e staticGeoEntry
+		for i, value := range record {
+			if i >= len(header) {
+				break
+			}
+			if err := e.set(strings.TrimSpace(header[i]), value); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (e *staticGeoEntry) set(field, value string) error {
+	if field == "network" {
+		e.Network = value
+		return nil
+	}
+	return e.GeoLocation.set(field, value)
+}
+
+func (p *StaticGeoProvider) Name() string    { return "static" }
+func (p *StaticGeoProvider) Cacheable() bool { return false }
+
+func (p *StaticGeoProvider) Lookup(_ context.Context, ip netip.Addr) (*GeoLocation, error) {
+	for i := range p.entries {
+		if p.entries[i].prefix.Contains(ip) {
+			loc := p.entries[i].GeoLocation
+			return &loc, nil
+		}
+	}
+	return nil, nil
+}
+
+// generic HTTP JSON service, fields are mapped with paths like "data.location.lat"
+type HttpGeoProvider struct {
+	url    string
+	fields map[string]string
+}
+
+func newHttpGeoProvider(url, fields string) (*HttpGeoProvider, error) {
+	if !strings.Contains(url, "{ip}") {
+		return nil, fmt.Errorf(`geolocation URL must contain "{ip}": %s`, url)
+	}
+	p := &HttpGeoProvider{url: url, fields: make(map[string]string)}
+	for pair := range strings.SplitSeq(fields, ",") {
+		field, path, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf(`invalid geolocation field mapping "%s": expected FIELD=PATH`, pair)
+		}
+		if err := (&GeoLocation{}).set(field, ""); err != nil {
+			return nil, err
+		}
+		p.fields[field] = path
+	}
+	return p, nil
+}
+
+func (p *HttpGeoProvider) Name() string    { return "http" }
+func (p *HttpGeoProvider) Cacheable() bool { return true }
+
+func (p *HttpGeoProvider) Lookup(ctx context.Context, ip netip.Addr) (*GeoLocation, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := strings.ReplaceAll(p.url, "{ip}", ip.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", settings().userAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		logErrorBody(body)
+		return nil, fmt.Errorf("geolocation service returned: %s", resp.Status)
+	}
+
+	var j any
+	if err := json.Unmarshal(body, &j); err != nil {
+		return nil, err
+	}
+
+	var loc GeoLocation
+	for field, path := range p.fields {
+		if value, ok := jsonPath(j, path); ok {
+			if err := loc.set(field, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if loc.Country == "" && loc.Lat == 0 && loc.Long == 0 {
+		return nil, nil
+	}
+	return &loc, nil
+}
+
+// jsonPath returns a scalar value at a dot-separated path as string, numeric segments index arrays
+func jsonPath(v any, path string) (string, bool) {
+	for key := range strings.SplitSeq(path, ".") {
+		switch t := v.(type) {
+		case map[string]any:
+			v = t[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return "", false
+			}
+			v = t[i]
+		default:
+			return "", false
+		}
+	}
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+func (loc *GeoLocation) set(field, value string) error {
+	parseFloat := func(s string) (float64, error) {
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	}
+	var err error
+	switch field {
+	case "country":
+		loc.Country = value
+	case "location":
+		loc.Location = value
+	case "lat":
+		loc.Lat, err = parseFloat(value)
+	case "long":
+		loc.Long, err = parseFloat(value)
+	case "accuracy":
+		loc.Accuracy, err = parseFloat(value)
+	case "isp":
+		loc.ISP = value
+	case "asn":
+		loc.ASN, err = parseAsn(value)
+	case "organization":
+		loc.Organization = value
+	case "domain":
+		loc.Domain = value
+	default:
+		return fmt.Errorf("unknown geolocation field: %s", field)
+	}
+	return err
+}
+
+// parseAsn accepts a plain number or the "AS24940 Hetzner Online GmbH" form of ip-api.com
+func parseAsn(s string) (uint32, error) {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), " ")
+	s = strings.TrimPrefix(strings.ToUpper(s), "AS")
+	if s == "" {
+		return 0, nil
+	}
+	asn, err := strconv.ParseUint(s, 10, 32)
+	return uint32(asn), err
+}
+
+func parseNetwork(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeGeoProvider struct {
+	name      string
+	cacheable bool
+	locations map[string]*GeoLocation
+	err       error
+	calls     int
+}
+
+func (p *fakeGeoProvider) Name() string    { return p.name }
+func (p *fakeGeoProvider) Cacheable() bool { return p.cacheable }
+
+func (p *fakeGeoProvider) Lookup(_ context.Context, ip netip.Addr) (*GeoLocation, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.locations[ip.String()], nil
+}
+
+func TestGeoProviderChain(t *testing.T) {
+	own := &GeoLocation{Country: "DE", Organization: "own datacenter"}
+	remote := &GeoLocation{Country: "US"}
+	static := &fakeGeoProvider{name: "static", locations: map[string]*GeoLocation{"1.1.1.1": own}}
+	failing := &fakeGeoProvider{name: "failing", cacheable: true, err: errors.New("timeout")}
+	service := &fakeGeoProvider{name: "http", cacheable: true, locations: map[string]*GeoLocation{"2.2.2.2": remote}}
+
+	cache := newGeoCache()
+	cache.providers = []GeoProvider{static, failing, service}
+
+	// local providers answer right away and are not cached
+	if got := cache.ipLocation("1.1.1.1", 1); got != own {
+		t.Errorf("got %+v, want location from static provider", got)
+	}
+	if len(cache.Entries) != 0 || cache.queueDepth() != 0 {
+		t.Errorf("got %d entries and %d queued, want none", len(cache.Entries), cache.queueDepth())
+	}
+
+	// remote providers are queried in background, in order, until one of them answers
+	if got := cache.ipLocation("2.2.2.2", 2); got != nil {
+		t.Errorf("got %+v before the lookup, want nil", got)
+	}
+	if cache.queueDepth() != 1 {
+		t.Fatalf("got %d queued, want 1", cache.queueDepth())
+	}
+	cache.resolve(context.Background(), <-cache.queue, nil)
+	if failing.calls != 1 || service.calls != 1 {
+		t.Errorf("got %d and %d calls, want 1 and 1", failing.calls, service.calls)
+	}
+	entry := cache.Entries["2.2.2.2"]
+	if entry.Location != remote || entry.Provider != "http" {
+		t.Errorf("got %+v, want entry from http provider", entry)
+	}
+	if got := cache.ipLocation("2.2.2.2", 2); got != remote {
+		t.Errorf("got %+v, want cached location", got)
+	}
+}
+
+func TestGeoResolveNotFound(t *testing.T) {
+	a := &fakeGeoProvider{name: "a", cacheable: true}
+	b := &fakeGeoProvider{name: "b", cacheable: true}
+	cache := newGeoCache()
+	cache.providers = []GeoProvider{a, b}
+	req := geoRequest{key: "3.3.3.3", addr: netip.MustParseAddr("3.3.3.3")}
+
+	cache.resolve(context.Background(), req, nil)
+	if entry, found := cache.Entries[req.key]; !found || entry.Reason != geoReasonNotFound {
+		t.Errorf("got %+v, want negative entry when no provider knows the IP", entry)
+	}
+
+	// errors are transient, the IP is not remembered as unknown
+	delete(cache.Entries, req.key)
+	a.err = errors.New("timeout")
+	cache.resolve(context.Background(), req, nil)
+	if entry, found := cache.Entries[req.key]; found {
+		t.Errorf("got %+v after a failed lookup, want no entry", entry)
+	}
+}
+
+func TestMaxMindDisabledUntilRestart(t *testing.T) {
+	calls := 0
+	status := http.StatusUnauthorized
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "key" {
+			t.Errorf("got credentials %q:%q", user, pass)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"country":{"iso_code":"DE"},"traits":{"autonomous_system_number":24940}}`))
+	}))
+	defer srv.Close()
+
+	p, err := newMaxMindProvider("user:key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.url = srv.URL + "/"
+	ip := netip.MustParseAddr("88.99.1.2")
+
+	status = http.StatusOK
+	loc, err := p.Lookup(context.Background(), ip)
+	if err != nil || loc == nil || loc.Country != "DE" || loc.ASN != 24940 {
+		t.Errorf("got %+v, %v", loc, err)
+	}
+
+	status = http.StatusNotFound
+	if loc, err := p.Lookup(context.Background(), ip); loc != nil || err != nil {
+		t.Errorf("got %+v, %v for unknown IP, want nil, nil", loc, err)
+	}
+
+	status = http.StatusPaymentRequired
+	if _, err := p.Lookup(context.Background(), ip); err == nil || errors.Is(err, errGeoProviderDisabled) {
+		t.Errorf("got %v, want the HTTP error", err)
+	}
+	status = http.StatusOK
+	if _, err := p.Lookup(context.Background(), ip); !errors.Is(err, errGeoProviderDisabled) {
+		t.Errorf("got %v, want provider disabled", err)
+	}
+	if calls != 3 {
+		t.Errorf("got %d requests, want 3", calls)
+	}
+}
+
+func TestStaticGeoProviderLongestPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "static.csv")
+	csv := "network,country,location,asn\n" +
+		"10.0.0.0/8,DE,Berlin,AS100 Own\n" +
+		"# the rack in Munich\n" +
+		"10.1.0.0/16,DE,Munich,100\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadStaticGeoProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.1.2.3", "Munich"},
+		{"10.2.3.4", "Berlin"},
+		{"11.0.0.1", ""},
+	}
+	for _, tt := range tests {
+		loc, err := p.Lookup(context.Background(), netip.MustParseAddr(tt.ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if loc != nil {
+			got = loc.Location
+			if loc.ASN != 100 {
+				t.Errorf("%s: got ASN %d, want 100", tt.ip, loc.ASN)
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"time"
+)
+
+const geoQueueSize = 10000
+
+type geoRequest struct {
+	key  string
+	addr netip.Addr
+}
+
+// enqueue schedules background resolution of the IP through remote providers, deduplicating in-flight keys
+func (cache *GeoCache) enqueue(key string, addr netip.Addr) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.pending[key] {
+		return
+	}
+	select {
+	case cache.queue <- geoRequest{key: key, addr: addr}:
+		cache.pending[key] = true
+	default:
+		// will be retried on the next update cycle
+		if metrics != nil {
+			metrics.ObserveGeoDropped("queue_full")
+		}
+	}
+}
+
+func (cache *GeoCache) queueDepth() int {
+	return len(cache.queue)
+}
+
+// workers stop when ctx is cancelled, requests left in the queue are retried after restart
+func (cache *GeoCache) startWorkers(ctx context.Context, workers int, qps float64) {
+	var limiter <-chan time.Time
+	if qps > 0 {
+		limiter = time.NewTicker(time.Duration(float64(time.Second) / qps)).C
+	}
+	for range max(workers, 1) {
+		go cache.worker(ctx, limiter)
+	}
+}
+
+func (cache *GeoCache) worker(ctx context.Context, limiter <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-cache.queue:
+			cache.resolve(ctx, req, limiter)
+
+			cache.mu.Lock()
+			delete(cache.pending, req.key)
+			cache.mu.Unlock()
+		}
+	}
+}
+
+func (cache *GeoCache) resolve(ctx context.Context, req geoRequest, limiter <-chan time.Time) {
+	failed := false
+	for _, provider := range cache.providers {
+		if !provider.Cacheable() {
+			continue
+		}
+
+		if !cache.spendBudget() {
+			if metrics != nil {
+				metrics.ObserveGeoDropped("budget")
+			}
+			return
+		}
+		if limiter != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-limiter:
+			}
+		}
+
+		start := time.Now()
+		location, err := provider.Lookup(ctx, req.addr)
+		if metrics != nil {
+			result := "found"
+			if err != nil {
+				result = "error"
+			} else if location == nil {
+				result = "not_found"
+			}
+			metrics.ObserveGeoLookup(provider.Name(), result, time.Since(start))
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				// shutting down, not a provider failure
+				return
+			}
+			if !errors.Is(err, errGeoProviderDisabled) {
+				slog.Error("Geolocation lookup failed", "provider", provider.Name(), "ip", req.addr.String(), "err", err)
+			}
+			failed = true
+			continue
+		}
+		if location == nil {
+			continue
+		}
+
+		cache.mu.Lock()
+		cache.Entries[req.key] = GeoCacheEntry{
+			Expires:  makeExpireTime(),
+			Location: location,
+			Provider: provider.Name(),
+		}
+		cache.mu.Unlock()
+		return
+	}
+
+	// errors are transient, so only remember IPs unknown to all providers
+	if !failed {
+		cache.storeNegative(req.key, geoReasonNotFound)
+	}
+}
+
+// spendBudget accounts one remote query against --geo-daily-budget, returns false if the budget is exhausted
+func (cache *GeoCache) spendBudget() bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	if cache.BudgetDay != today {
+		cache.BudgetDay = today
+		cache.BudgetUsed = 0
+	}
+	if budget := settings().geoDailyBudget; budget > 0 && cache.BudgetUsed >= budget {
+		return false
+	}
+	cache.BudgetUsed++
+	if metrics != nil {
+		metrics.geoBudgetUsed.Set(float64(cache.BudgetUsed))
+	}
+	return true
+}
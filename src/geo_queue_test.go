@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timedGeoProvider records when it was called, it is safe for concurrent workers
+type timedGeoProvider struct {
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (p *timedGeoProvider) Name() string    { return "timed" }
+func (p *timedGeoProvider) Cacheable() bool { return true }
+
+func (p *timedGeoProvider) Lookup(_ context.Context, _ netip.Addr) (*GeoLocation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, time.Now())
+	return &GeoLocation{Country: "DE"}, nil
+}
+
+func (p *timedGeoProvider) callTimes() []time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]time.Time(nil), p.calls...)
+}
+
+func TestGeoQueueDropsDuplicates(t *testing.T) {
+	cache := newGeoCache()
+	cache.providers = []GeoProvider{&timedGeoProvider{}}
+
+	cache.ipLocation("1.2.3.4", 1)
+	cache.ipLocation("1.2.3.4", 2)
+	// addresses of the same IPv6 /64 are looked up once
+	cache.ipLocation("2a01:4f8:1:2::1", 3)
+	cache.ipLocation("2a01:4f8:1:2::2", 4)
+	if got := cache.queueDepth(); got != 2 {
+		t.Errorf("got %d queued, want 2", got)
+	}
+
+	// keys being resolved are not queued again until the lookup finishes
+	req := <-cache.queue
+	cache.enqueue(req.key, req.addr)
+	if got := cache.queueDepth(); got != 1 {
+		t.Errorf("got %d queued while in flight, want 1", got)
+	}
+	cache.mu.Lock()
+	delete(cache.pending, req.key)
+	cache.mu.Unlock()
+	cache.enqueue(req.key, req.addr)
+	if got := cache.queueDepth(); got != 2 {
+		t.Errorf("got %d queued after the lookup, want 2", got)
+	}
+}
+
+func TestGeoQueueRejectsInvalidIps(t *testing.T) {
+	cache := newGeoCache()
+	cache.providers = []GeoProvider{&timedGeoProvider{}}
+
+	for _, ip := range []string{"not an ip", "", "10.1.2.3", "127.0.0.1", "0.0.0.0"} {
+		if got := cache.ipLocation(ip, 1); got != nil {
+			t.Errorf("%q: got %+v, want nil", ip, got)
+		}
+	}
+	if len(cache.Entries) != 0 || cache.queueDepth() != 0 {
+		t.Errorf("got %v entries and %d queued, want none", cache.Entries, cache.queueDepth())
+	}
+}
+
+func TestGeoQueueDailyBudget(t *testing.T) {
+	currentSettings.Store(&runtimeSettings{geoDailyBudget: 2})
+	t.Cleanup(func() { currentSettings.Store(nil) })
+
+	provider := &timedGeoProvider{}
+	cache := newGeoCache()
+	cache.providers = []GeoProvider{provider}
+
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		cache.resolve(context.Background(), geoRequest{key: ip, addr: netip.MustParseAddr(ip)}, nil)
+	}
+	if got := len(provider.callTimes()); got != 2 {
+		t.Errorf("got %d lookups, want 2", got)
+	}
+	// a dropped request is not remembered as unknown, it is retried later
+	if _, found := cache.Entries["3.3.3.3"]; found {
+		t.Errorf("got entry of the IP dropped because of the budget")
+	}
+
+	// the budget is reset on the next day
+	cache.BudgetDay = time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	if !cache.spendBudget() || cache.BudgetUsed != 1 {
+		t.Errorf("budget was not reset, used %d", cache.BudgetUsed)
+	}
+}
+
+func TestGeoQueueQpsLimit(t *testing.T) {
+	provider := &timedGeoProvider{}
+	cache := newGeoCache()
+	cache.providers = []GeoProvider{provider}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const qps = 20
+	cache.startWorkers(ctx, 4, qps)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4"} {
+		cache.enqueue(ip, netip.MustParseAddr(ip))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(provider.callTimes()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	calls := provider.callTimes()
+	if len(calls) != 4 {
+		t.Fatalf("got %d lookups, want 4", len(calls))
+	}
+	// several workers share one limiter, allow some jitter of the ticker
+	if elapsed := calls[3].Sub(calls[0]); elapsed < 3*time.Second/qps*8/10 {
+		t.Errorf("4 lookups took %v, want at least %v", elapsed, 3*time.Second/qps)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+const geoIpDbCheckInterval = time.Minute
+
+// local GeoIP2/GeoLite2 databases (City, ASN, ISP), hot-reloaded when files change
+type GeoIpDb struct {
+	mu    sync.RWMutex
+	files []*geoIpDbFile
+}
+
+type geoIpDbFile struct {
+	path   string
+	mtime  time.Time
+	reader *maxminddb.Reader
+}
+
+// fields of GeoIP2/GeoLite2 City databases, same as in the web service except accuracy_radius, which is uint16 here
+type mmdbCityRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Traits struct {
+		Isp                    string `maxminddb:"isp"`
+		AutonomousSystemNumber uint32 `maxminddb:"autonomous_system_number"`
+		Organization           string `maxminddb:"organization"`
+		Domain                 string `maxminddb:"domain"`
+	} `maxminddb:"traits"`
+	City struct {
+		Names struct {
+			En string `maxminddb:"en"`
+		} `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
+		Lat            float64 `maxminddb:"latitude"`
+		Long           float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	SubDivisions []MaxMindSubdivision `maxminddb:"subdivisions"`
+}
+
+func (rec *mmdbCityRecord) response() *MaxMindResponse {
+	var r MaxMindResponse
+	r.Country.IsoCode = rec.Country.IsoCode
+	r.Traits.Isp = rec.Traits.Isp
+	r.Traits.AutonomousSystemNumber = rec.Traits.AutonomousSystemNumber
+	r.Traits.Organization = rec.Traits.Organization
+	r.Traits.Domain = rec.Traits.Domain
+	r.City.Names.En = rec.City.Names.En
+	r.Location.Accuracy = float64(rec.Location.AccuracyRadius)
+	r.Location.Lat = rec.Location.Lat
+	r.Location.Long = rec.Location.Long
+	r.SubDivisions = rec.SubDivisions
+	return &r
+}
+
+// fields of GeoIP2-ISP and GeoLite2-ASN databases
+type mmdbIspRecord struct {
+	Isp            string `maxminddb:"isp"`
+	Organization   string `maxminddb:"organization"`
+	AsNumber       uint32 `maxminddb:"autonomous_system_number"`
+	AsOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+func openGeoIpDb(paths string) (*GeoIpDb, error) {
+	db := &GeoIpDb{}
+	for path := range strings.SplitSeq(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		f, err := openGeoIpDbFile(path)
+		if err != nil {
+			return nil, err
+		}
+		db.files = append(db.files, f)
+	}
+	return db, nil
+}
+
+func openGeoIpDbFile(path string) (*geoIpDbFile, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	slog.Info("Loaded GeoIP database", "path", path,
+		"type", reader.Metadata.DatabaseType, "built", reader.Metadata.BuildTime().Format(time.DateOnly))
+	return &geoIpDbFile{path: path, mtime: stat.ModTime(), reader: reader}, nil
+}
+
+func (db *GeoIpDb) Name() string    { return "geoip-db" }
+func (db *GeoIpDb) Cacheable() bool { return false }
+
+// Lookup returns nil if the IP is not found in City database
+func (db *GeoIpDb) Lookup(_ context.Context, ip netip.Addr) (*GeoLocation, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var city mmdbCityRecord
+	found := false
+	for _, f := range db.files {
+		result := f.reader.Lookup(ip)
+		if !result.Found() {
+			continue
+		}
+		var err error
+		if strings.Contains(f.reader.Metadata.DatabaseType, "City") {
+			err = result.Decode(&city)
+			found = true
+		} else {
+			var isp mmdbIspRecord
+			err = result.Decode(&isp)
+			if isp.Isp != "" {
+				city.Traits.Isp = isp.Isp
+			} else if city.Traits.Isp == "" {
+				city.Traits.Isp = isp.AsOrganization
+			}
+			if isp.AsNumber != 0 {
+				city.Traits.AutonomousSystemNumber = isp.AsNumber
+			}
+			if isp.Organization != "" {
+				city.Traits.Organization = isp.Organization
+			}
+		}
+		if err != nil {
+			slog.Error("GeoIP database lookup failed", "path", f.path, "err", err)
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	return city.response().geoLocation(), nil
+}
+
+func (db *GeoIpDb) reloadIfChanged() {
+	for i, f := range db.files {
+		stat, err := os.Stat(f.path)
+		if err != nil {
+			slog.Error("Could not reload GeoIP database", "path", f.path, "err", err)
+			continue
+		}
+		if stat.ModTime().Equal(f.mtime) {
+			continue
+		}
+		newFile, err := openGeoIpDbFile(f.path)
+		if err != nil {
+			// keep using the old one, probably the file is being written
+			slog.Error("Could not reload GeoIP database", "path", f.path, "err", err)
+			continue
+		}
+		db.mu.Lock()
+		db.files[i] = newFile
+		db.mu.Unlock()
+		_ = f.reader.Close()
+	}
+}
+
+// watch reloads changed database files until ctx is cancelled
+func (db *GeoIpDb) watch(ctx context.Context) {
+	ticker := time.NewTicker(geoIpDbCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			db.reloadIfChanged()
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// mmdbUint16 and mmdbUint32 select the MaxMind DB data type of integers in mmdbRecord
+type mmdbUint16 uint16
+type mmdbUint32 uint32
+
+type mmdbRecord map[string]any
+
+// writeTestMmdb writes an IPv4 database in MaxMind DB format where every address maps to record
+func writeTestMmdb(t *testing.T, dbType string, record mmdbRecord) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	// search tree of a single node, both records point to offset 0 of the data section:
+	// node_count + 16 (data section separator) + offset
+	const nodeCount = 1
+	for range 2 {
+		buf.Write([]byte{0, 0, nodeCount + 16})
+	}
+	buf.Write(make([]byte, 16))
+	mmdbEncode(&buf, record)
+
+	buf.WriteString("\xAB\xCD\xEFMaxMind.com")
+	mmdbEncode(&buf, mmdbRecord{
+		"node_count":                  mmdbUint32(nodeCount),
+		"record_size":                 mmdbUint16(24),
+		"ip_version":                  mmdbUint16(4),
+		"database_type":               dbType,
+		"languages":                   []any{"en"},
+		"binary_format_major_version": mmdbUint16(2),
+		"binary_format_minor_version": mmdbUint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"description":                 mmdbRecord{"en": "test"},
+	})
+
+	path := filepath.Join(t.TempDir(), dbType+".mmdb")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mmdbControl(buf *bytes.Buffer, typ int, size int) {
+	sizeField := min(size, 29)
+	if size >= 29+256 {
+		panic("size is too big for the test encoder")
+	}
+	if typ <= 7 {
+		buf.WriteByte(byte(typ<<5 | sizeField))
+	} else {
+		buf.WriteByte(byte(sizeField))
+		buf.WriteByte(byte(typ - 7))
+	}
+	if size >= 29 {
+		buf.WriteByte(byte(size - 29))
+	}
+}
+
+func mmdbEncode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		mmdbControl(buf, 2, len(v))
+		buf.WriteString(v)
+	case float64:
+		mmdbControl(buf, 3, 8)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case mmdbUint16:
+		mmdbControl(buf, 5, 2)
+		_ = binary.Write(buf, binary.BigEndian, uint16(v))
+	case mmdbUint32:
+		mmdbControl(buf, 6, 4)
+		_ = binary.Write(buf, binary.BigEndian, uint32(v))
+	case uint64:
+		mmdbControl(buf, 9, 8)
+		_ = binary.Write(buf, binary.BigEndian, v)
+	case mmdbRecord:
+		mmdbControl(buf, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			mmdbEncode(buf, k)
+			mmdbEncode(buf, v[k])
+		}
+	case []any:
+		mmdbControl(buf, 11, len(v))
+		for _, item := range v {
+			mmdbEncode(buf, item)
+		}
+	default:
+		panic("unsupported type")
+	}
+}
+
+func TestGeoIpDbLookup(t *testing.T) {
+	city := writeTestMmdb(t, "GeoLite2-City", mmdbRecord{
+		"country": mmdbRecord{"iso_code": "DE"},
+		"city":    mmdbRecord{"names": mmdbRecord{"en": "Falkenstein"}},
+		"location": mmdbRecord{
+			"accuracy_radius": mmdbUint16(20),
+			"latitude":        50.4777,
+			"longitude":       12.3649,
+		},
+		"subdivisions": []any{
+			mmdbRecord{"iso_code": "SN", "names": mmdbRecord{"en": "Saxony"}},
+		},
+	})
+	asn := writeTestMmdb(t, "GeoLite2-ASN", mmdbRecord{
+		"autonomous_system_number":       mmdbUint32(24940),
+		"autonomous_system_organization": "Hetzner Online GmbH",
+	})
+
+	db, err := openGeoIpDb(city + "," + asn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := db.Lookup(context.Background(), netip.MustParseAddr("88.99.1.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GeoLocation{
+		Country:  "DE",
+		Location: "Falkenstein, Saxony",
+		Lat:      50.4777,
+		Long:     12.3649,
+		Accuracy: 20,
+		ISP:      "Hetzner Online GmbH",
+		ASN:      24940,
+	}
+	if loc == nil || *loc != want {
+		t.Errorf("got %+v, want %+v", loc, want)
+	}
+}
+
+func TestGeoIpDbLookupWithoutCity(t *testing.T) {
+	asn := writeTestMmdb(t, "GeoLite2-ASN", mmdbRecord{
+		"autonomous_system_organization": "Hetzner Online GmbH",
+	})
+	db, err := openGeoIpDb(asn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := db.Lookup(context.Background(), netip.MustParseAddr("88.99.1.2"))
+	if err != nil || loc != nil {
+		t.Errorf("got %+v, %v, want nil without City database", loc, err)
+	}
+}
+
+func TestGeoIpDbWatchStops(t *testing.T) {
+	asn := writeTestMmdb(t, "GeoLite2-ASN", mmdbRecord{
+		"autonomous_system_organization": "Hetzner Online GmbH",
+	})
+	db, err := openGeoIpDb(asn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		db.watch(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop after the context was cancelled")
+	}
+}
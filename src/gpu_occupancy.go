@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+)
+
+// states of a GPU slot as encoded in the gpu_occupancy field of /machines
+var gpuSlotStates = []string{"ondemand", "reserved", "bid", "free", "unknown"}
+
+// parseGpuOccupancy splits gpu_occupancy (e.g. "D D I x") into per-GPU slot states, in GPU index order
+func parseGpuOccupancy(occupancy string) []string {
+	result := []string{}
+	for _, r := range strings.ReplaceAll(occupancy, " ", "") {
+		switch r {
+		case 'D':
+			result = append(result, "ondemand")
+		case 'R':
+			result = append(result, "reserved")
+		case 'I':
+			result = append(result, "bid")
+		case 'x', 'X', '-', '_':
+			result = append(result, "free")
+		default:
+			result = append(result, "unknown")
+		}
+	}
+	return result
+}
+
+// rentableGpuIds returns the set of GPU ids which are in rentable (free) chunks according to market offers
+func (m *VastAiMachineOffer) rentableGpuIds() map[int]bool {
+	result := make(map[int]bool)
+	for _, chunk := range m.Chunks {
+		if chunk.Rentable {
+			for _, id := range chunk.GpuIds {
+				result[id] = true
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseGpuOccupancy(t *testing.T) {
+	tests := []struct {
+		occupancy string
+		want      []string
+	}{
+		{"", []string{}},
+		{"D D I x", []string{"ondemand", "ondemand", "bid", "free"}},
+		{"RX-_", []string{"reserved", "free", "free", "free"}},
+		{"D ? ", []string{"ondemand", "unknown"}},
+	}
+	for _, tt := range tests {
+		if got := parseGpuOccupancy(tt.occupancy); !slices.Equal(got, tt.want) {
+			t.Errorf("parseGpuOccupancy(%q) = %v, want %v", tt.occupancy, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// data sources, each updated by its own scheduler
+const (
+	sourceOffers    = "offers"
+	sourceMachines  = "machines"
+	sourceInstances = "instances"
+	sourcePayouts   = "payouts"
+)
+
+// offer data older than this many update intervals makes the exporter not ready
+const readyMaxIntervals = 5
+
+// readyMinAge keeps short update intervals (e.g. with --master-url) from flapping readiness
+const readyMinAge = 5 * time.Minute
+
+// sources of account data, all of them are required for readiness with an API key
+var accountSources = []string{sourceMachines, sourceInstances, sourcePayouts}
+
+type updateHealth struct {
+	mu          sync.Mutex
+	lastSuccess map[string]time.Time
+	// whether the last fetch of the source failed
+	failed    map[string]bool
+	lastError map[string]sourceError
+}
+
+type sourceError struct {
+	err string
+	ts  time.Time
+}
+
+type SourceStatus struct {
+	LastSuccess   time.Time `json:"last_success,omitzero"`
+	Failing       bool      `json:"failing"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitzero"`
+}
+
+var health = updateHealth{
+	lastSuccess: make(map[string]time.Time),
+	failed:      make(map[string]bool),
+	lastError:   make(map[string]sourceError),
+}
+
+func (h *updateHealth) markUpdated(source string) {
+	now := time.Now()
+	h.mu.Lock()
+	h.lastSuccess[source] = now
+	h.failed[source] = false
+	h.mu.Unlock()
+
+	if metrics != nil {
+		metrics.ObserveUpdateSuccess(source, now)
+	}
+}
+
+func (h *updateHealth) markFailed(source string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failed[source] = true
+	h.lastError[source] = sourceError{err: err.Error(), ts: time.Now()}
+}
+
+// status returns the state of each source which was fetched at least once
+func (h *updateHealth) status() map[string]SourceStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := make(map[string]SourceStatus)
+	for _, source := range []string{sourceOffers, sourceMachines, sourceInstances, sourcePayouts} {
+		lastSuccess, lastError := h.lastSuccess[source], h.lastError[source]
+		if lastSuccess.IsZero() && lastError.ts.IsZero() {
+			continue
+		}
+		result[source] = SourceStatus{
+			LastSuccess:   lastSuccess.UTC(),
+			Failing:       h.failed[source],
+			LastError:     lastError.err,
+			LastErrorTime: lastError.ts.UTC(),
+		}
+	}
+	return result
+}
+
+// problems returns reasons why the exporter is not ready, empty if it is
+func (h *updateHealth) problems(useAccount bool) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var result []string
+	if offerCache.Timestamp().IsZero() {
+		result = append(result, "offer data is not loaded yet")
+	} else {
+		maxAge := max(readyMaxIntervals*settings().updateInterval, readyMinAge)
+		if age := time.Since(h.lastSuccess[sourceOffers]); age > maxAge {
+			result = append(result, fmt.Sprintf("offer data was not updated for %s", age.Round(time.Second)))
+		}
+	}
+	if useAccount {
+		for _, source := range accountSources {
+			if h.lastSuccess[source].IsZero() {
+				result = append(result, fmt.Sprintf("%s data is not loaded yet", source))
+			} else if h.failed[source] {
+				result = append(result, fmt.Sprintf("last %s data update failed", source))
+			}
+		}
+	}
+	return result
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "OK")
+}
+
+func readyzHandler(w http.ResponseWriter, r *http.Request, useAccount bool) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if problems := health.problems(useAccount); len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "OK")
+}
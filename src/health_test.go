@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestReadinessProblems(t *testing.T) {
+	currentSettings.Store(&runtimeSettings{updateInterval: time.Minute})
+	offerCache.mu.Lock()
+	prevTs := offerCache.ts
+	offerCache.ts = time.Now()
+	offerCache.mu.Unlock()
+	t.Cleanup(func() {
+		currentSettings.Store(nil)
+		offerCache.mu.Lock()
+		offerCache.ts = prevTs
+		offerCache.mu.Unlock()
+	})
+
+	now := time.Now()
+	h := updateHealth{
+		lastSuccess: map[string]time.Time{
+			sourceOffers:    now,
+			sourceMachines:  now.Add(-3 * time.Minute),
+			sourceInstances: now.Add(-11 * time.Minute),
+			sourcePayouts:   now.Add(-49 * time.Minute),
+		},
+		failed:    make(map[string]bool),
+		lastError: make(map[string]sourceError),
+	}
+	if got := h.problems(true); len(got) != 0 {
+		t.Errorf("got %q, want none", got)
+	}
+
+	h.markFailed(sourceMachines, errors.New("timeout"))
+	got := h.problems(true)
+	want := []string{"last machines data update failed"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := h.problems(false); len(got) != 0 {
+		t.Errorf("got %q without account, want none", got)
+	}
+
+	h.markUpdated(sourceMachines)
+	delete(h.lastSuccess, sourcePayouts)
+	got = h.problems(true)
+	want = []string{"payouts data is not loaded yet"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+type HostClass string
+
+const (
+	HostClassDatacenter HostClass = "datacenter" // marked as datacenter by Vast.ai
+	HostClassColo       HostClass = "colo"       // hosting provider infrastructure without Vast.ai certification
+	HostClassProsumer   HostClass = "prosumer"   // serious home or office setups
+	HostClassHobbyist   HostClass = "hobbyist"   // a few consumer GPUs on a residential connection
+)
+
+// score thresholds between classes of non-datacenter hosts
+const (
+	hostClassProsumerScore = 2
+	hostClassColoScore     = 5
+)
+
+type ispKind int
+
+const (
+	ispUnknown ispKind = iota
+	ispHosting
+	ispResidential
+)
+
+// ASNs of well-known hosting providers and residential ISPs, checked before names
+var knownAsns = map[uint32]ispKind{
+	// hosting
+	24940:  ispHosting, // Hetzner
+	213230: ispHosting, // Hetzner
+	16276:  ispHosting, // OVH
+	14061:  ispHosting, // DigitalOcean
+	16509:  ispHosting, // Amazon
+	14618:  ispHosting, // Amazon
+	15169:  ispHosting, // Google
+	396982: ispHosting, // Google
+	8075:   ispHosting, // Microsoft
+	31898:  ispHosting, // Oracle
+	20473:  ispHosting, // Vultr (Choopa)
+	63949:  ispHosting, // Linode (Akamai)
+	60781:  ispHosting, // LeaseWeb
+	28753:  ispHosting, // LeaseWeb
+	9009:   ispHosting, // M247
+	212238: ispHosting, // Datacamp
+	40676:  ispHosting, // Psychz
+	8100:   ispHosting, // QuadraNet
+	// residential
+	7922:  ispResidential, // Comcast
+	16591: ispResidential, // Google Fiber, would be hosting by name
+	20115: ispResidential, // Charter (Spectrum)
+	11427: ispResidential, // Charter (Spectrum)
+	20001: ispResidential, // Charter (Spectrum)
+	22773: ispResidential, // Cox
+	701:   ispResidential, // Verizon
+	7018:  ispResidential, // AT&T
+	21928: ispResidential, // T-Mobile US
+	209:   ispResidential, // CenturyLink
+	5650:  ispResidential, // Frontier
+	3320:  ispResidential, // Deutsche Telekom
+	3209:  ispResidential, // Vodafone Germany
+	3215:  ispResidential, // Orange France
+	3352:  ispResidential, // Telefonica Spain
+	5089:  ispResidential, // Virgin Media
+	2856:  ispResidential, // BT
+	5607:  ispResidential, // Sky UK
+	812:   ispResidential, // Rogers
+	577:   ispResidential, // Bell Canada
+	6327:  ispResidential, // Shaw
+	852:   ispResidential, // Telus
+	4134:  ispResidential, // Chinanet
+	4837:  ispResidential, // China Unicom
+	7552:  ispResidential, // Viettel
+	12389: ispResidential, // Rostelecom
+}
+
+// whole words or phrases in ISP/organization names of hosting providers and residential ISPs, lowercase;
+// names are split into words on anything except letters, digits and "&", so "servers.com" is "servers com"
+var (
+	hostingIspKeywords = []string{
+		"hosting", "datacenter", "datacenters", "data center", "data centre", "colo", "colocation", "cloud",
+		"server", "servers", "dedicated", "hetzner", "ovh", "ovhcloud", "equinix", "leaseweb", "digitalocean",
+		"linode", "akamai", "amazon", "google", "microsoft", "oracle", "vultr", "choopa", "cogent",
+		"hurricane electric", "zayo", "coresite", "cyrusone", "flexential", "psychz", "quadranet", "tierpoint",
+		"servers com", "m247", "datacamp",
+	}
+	residentialIspKeywords = []string{
+		"comcast", "charter", "spectrum", "cox", "verizon", "at&t", "t mobile", "centurylink", "frontier",
+		"telekom", "vodafone", "orange", "telefonica", "movistar", "virgin media", "bt", "sky", "rogers",
+		"bell canada", "shaw", "telus", "broadband", "cable", "dsl", "fiber", "fibre", "mobile", "wireless",
+		"residential", "chinanet", "china unicom", "viettel", "rostelecom",
+	}
+)
+
+// words of datacenter-grade GPU model names, lowercase (e.g. "H100 SXM", "Tesla V100", "L40S")
+var datacenterGpuWords = []string{
+	"h100", "h200", "h800", "gh200", "a100", "a100x", "a800", "b200", "b300",
+	"l40", "l40s", "a40", "l4", "v100", "mi250x", "mi300x",
+}
+
+type hostClassInfo struct {
+	class      HostClass
+	confidence float64
+}
+
+// classifyHosts sets host class of each machine based on all machines of the same host
+func (machines VastAiMachineOffers) classifyHosts() {
+	type hostSignals struct {
+		machines      int
+		gpus          int
+		datacenter    bool
+		staticIp      bool
+		datacenterGpu bool
+		inetDown      float64
+		location      *GeoLocation
+	}
+	hosts := make(map[int]*hostSignals)
+	for i := range machines {
+		m := &machines[i]
+		h := hosts[m.HostId]
+		if h == nil {
+			h = &hostSignals{}
+			hosts[m.HostId] = h
+		}
+		h.machines++
+		h.gpus += m.NumGpus
+		h.datacenter = h.datacenter || m.Datacenter
+		h.staticIp = h.staticIp || m.StaticIp
+		h.datacenterGpu = h.datacenterGpu || isDatacenterGpu(m.GpuName)
+		h.inetDown = max(h.inetDown, m.InetDown)
+		if h.location == nil {
+			h.location = m.Location
+		}
+	}
+
+	classes := make(map[int]hostClassInfo, len(hosts))
+	for hostId, h := range hosts {
+		score := 0.0
+		known := 0 // number of signals with a definite value, lowers confidence when small
+
+		if h.location != nil {
+			switch classifyIsp(h.location) {
+			case ispHosting:
+				score += 3
+				known++
+			case ispResidential:
+				score -= 2
+				known++
+			}
+		}
+		if h.staticIp {
+			score += 1
+		} else {
+			score -= 1
+		}
+		known++
+		switch {
+		case h.machines >= 4:
+			score += 2
+		case h.machines >= 2:
+			score += 1
+		}
+		switch {
+		case h.gpus >= 16:
+			score += 2
+		case h.gpus >= 8:
+			score += 1
+		}
+		if h.datacenterGpu {
+			score += 2
+			known++
+		}
+		if h.inetDown > 0 {
+			switch {
+			case h.inetDown >= 1000:
+				score += 1
+			case h.inetDown < 200:
+				score -= 1
+			}
+			known++
+		}
+
+		var info hostClassInfo
+		var margin float64 // distance of the score from the nearest class boundary
+		switch {
+		case h.datacenter:
+			info.class = HostClassDatacenter
+			margin = 3 + max(score, 0)
+		case score >= hostClassColoScore:
+			info.class = HostClassColo
+			margin = score - hostClassColoScore + 1
+		case score >= hostClassProsumerScore:
+			info.class = HostClassProsumer
+			margin = min(score-hostClassProsumerScore+1, hostClassColoScore-score)
+		default:
+			info.class = HostClassHobbyist
+			margin = hostClassProsumerScore - score
+		}
+		info.confidence = 0.5 + 0.5*min(margin/4, 1)
+		if known < 3 {
+			info.confidence *= 0.8
+		}
+		info.confidence = math.Round(info.confidence*100) / 100
+		classes[hostId] = info
+	}
+
+	for i := range machines {
+		info := classes[machines[i].HostId]
+		machines[i].HostClass = info.class
+		machines[i].ClassConfidence = info.confidence
+	}
+}
+
+func isDatacenterGpu(gpuName string) bool {
+	for word := range strings.FieldsSeq(strings.ToLower(gpuName)) {
+		if slices.Contains(datacenterGpuWords, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyIsp tells the kind of the network by ASN if it is known, otherwise by words of ISP/organization/domain
+func classifyIsp(loc *GeoLocation) ispKind {
+	if kind, found := knownAsns[loc.ASN]; found {
+		return kind
+	}
+	words := nameWords(loc.ISP + " " + loc.Organization + " " + loc.Domain)
+	switch {
+	case containsAnyWords(words, hostingIspKeywords):
+		return ispHosting
+	case containsAnyWords(words, residentialIspKeywords):
+		return ispResidential
+	}
+	return ispUnknown
+}
+
+// nameWords splits a name into lowercase words, returned with a space on both sides for whole-word matching
+func nameWords(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '&'
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+func containsAnyWords(words string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(words, " "+keyword+" ") {
+			return true
+		}
+	}
+	return false
+}
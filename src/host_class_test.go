@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func TestClassifyIsp(t *testing.T) {
+	tests := []struct {
+		isp  string
+		org  string
+		asn  uint32
+		want ispKind
+	}{
+		{"Hetzner Online GmbH", "", 24940, ispHosting},
+		{"Hetzner Online GmbH", "", 0, ispHosting},
+		{"OVH SAS", "", 0, ispHosting},
+		{"Servers.com, Inc.", "", 0, ispHosting},
+		{"Colocation America Corporation", "", 0, ispHosting},
+		{"Amazon.com, Inc.", "AWS EC2 (us-east-1)", 16509, ispHosting},
+		{"Comcast Cable Communications, LLC", "", 7922, ispResidential},
+		{"Comcast Cable Communications, LLC", "", 0, ispResidential},
+		{"Deutsche Telekom AG", "", 0, ispResidential},
+		{"T-Mobile USA, Inc.", "", 0, ispResidential},
+		{"AT&T Services, Inc.", "", 0, ispResidential},
+		{"British Telecommunications PLC", "", 2856, ispResidential},
+		// ASN takes precedence over words of the name
+		{"Google Fiber Inc.", "", 16591, ispResidential},
+		// substrings of words don't match
+		{"Colombia Telecomunicaciones S.A. E.S.P.", "", 0, ispUnknown},
+		{"Telmex Colombia S.A.", "", 0, ispUnknown},
+		{"Skyline Network Group", "", 0, ispUnknown},
+		{"Cloudflare, Inc.", "", 0, ispUnknown},
+		{"Scoxa Internet", "", 0, ispUnknown},
+		{"", "", 0, ispUnknown},
+	}
+	for _, tt := range tests {
+		got := classifyIsp(&GeoLocation{ISP: tt.isp, Organization: tt.org, ASN: tt.asn})
+		if got != tt.want {
+			t.Errorf("classifyIsp(%q, %q, AS%d) = %v, want %v", tt.isp, tt.org, tt.asn, got, tt.want)
+		}
+	}
+}
+
+func TestNameWords(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Servers.com, Inc.", " servers com inc "},
+		{"AT&T Services", " at&t services "},
+		{"T-Mobile", " t mobile "},
+		{"", "  "},
+	}
+	for _, tt := range tests {
+		if got := nameWords(tt.name); got != tt.want {
+			t.Errorf("nameWords(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyHosts(t *testing.T) {
+	hetzner := &GeoLocation{ISP: "Hetzner Online GmbH", ASN: 24940}
+	comcast := &GeoLocation{ISP: "Comcast Cable Communications, LLC", ASN: 7922}
+	colombia := &GeoLocation{ISP: "Colombia Movil"}
+	machines := VastAiMachineOffers{
+		{HostId: 1, Datacenter: true, NumGpus: 8, GpuName: "H100 SXM"},
+		{HostId: 2, NumGpus: 8, GpuName: "A100 PCIE", StaticIp: true, InetDown: 5000, Location: hetzner},
+		{HostId: 3, NumGpus: 1, GpuName: "RTX 3090", InetDown: 100, Location: comcast},
+		{HostId: 4, NumGpus: 4, GpuName: "RTX 4090", StaticIp: true, InetDown: 1000, Location: colombia},
+	}
+	machines.classifyHosts()
+
+	want := []HostClass{HostClassDatacenter, HostClassColo, HostClassHobbyist, HostClassProsumer}
+	for i, class := range want {
+		if machines[i].HostClass != class {
+			t.Errorf("host %d: got %s, want %s", machines[i].HostId, machines[i].HostClass, class)
+		}
+		if c := machines[i].ClassConfidence; c < 0.4 || c > 1 {
+			t.Errorf("host %d: confidence %v out of range", machines[i].HostId, c)
+		}
+	}
+}
@@ -16,6 +16,7 @@ type MapLocation struct {
 	Long         float64 `json:"long"`
 	Accuracy     float64 `json:"accuracy"` // in kilometers
 	ISP          string  `json:"isp"`
+	ASN          uint32  `json:"asn"`
 	Organization string  `json:"organization"`
 	Domain       string  `json:"domain"`
 }
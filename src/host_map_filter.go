@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HostMapFilter is a composable filter for /host-map-data, all conditions must match
+type HostMapFilter struct {
+	Gpus       []string // GPU models, lowercase, host must have any of them
+	Countries  []string // ISO codes, uppercase
+	MinTflops  float64
+	Top        int // largest N hosts by TFLOPS after other conditions are applied
+	Verified   *bool
+	Datacenter *bool
+	Isp        string // lowercase substring of ISP or organization
+}
+
+var trueValue, falseValue = true, false
+
+// pre-built ?filter= presets expressed as filter conditions
+var hostMapPresets = map[string]func(f *HostMapFilter){
+	"all":     func(f *HostMapFilter) {},
+	"dc":      func(f *HostMapFilter) { f.Datacenter = &trueValue },
+	"non-dc":  func(f *HostMapFilter) { f.Datacenter = &falseValue },
+	"top-10":  func(f *HostMapFilter) { f.Top = 10 },
+	"top-100": func(f *HostMapFilter) { f.Top = 100 },
+}
+
+// filter parameters in the order they are applied, after the ?filter= preset, so they override it
+var hostMapFilterParams = []string{"gpu", "country", "min_tflops", "top", "verified", "datacenter", "isp"}
+
+// parseHostMapFilter ignores unknown parameters (e.g. cache busters added by dashboards),
+// only invalid values of known ones are rejected
+func parseHostMapFilter(query url.Values) (*HostMapFilter, error) {
+	f := &HostMapFilter{}
+	if value, found := lastParam(query, "filter"); found {
+		preset, found := hostMapPresets[value]
+		if !found {
+			return nil, fmt.Errorf("unknown filter: %s", value)
+		}
+		preset(f)
+	}
+	for _, name := range hostMapFilterParams {
+		value, found := lastParam(query, name)
+		if !found {
+			continue
+		}
+		var err error
+		switch name {
+		case "gpu":
+			f.Gpus = splitList(value, func(s string) string {
+				return strings.ToLower(strings.ReplaceAll(s, "_", " "))
+			})
+		case "country":
+			f.Countries = splitList(value, strings.ToUpper)
+		case "min_tflops":
+			f.MinTflops, err = strconv.ParseFloat(value, 64)
+		case "top":
+			f.Top, err = strconv.Atoi(value)
+			if err == nil && f.Top <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "verified":
+			f.Verified, err = parseBoolParam(value)
+		case "datacenter":
+			f.Datacenter, err = parseBoolParam(value)
+		case "isp":
+			f.Isp = strings.ToLower(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return f, nil
+}
+
+func lastParam(query url.Values, name string) (string, bool) {
+	values := query[name]
+	if len(values) == 0 {
+		return "", false
+	}
+	return strings.TrimSpace(values[len(values)-1]), true
+}
+
+func parseBoolParam(value string) (*bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func splitList(value string, normalize func(string) string) []string {
+	var result []string
+	for s := range strings.SplitSeq(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			result = append(result, normalize(s))
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// Key returns the normalized query, equal filters have equal keys regardless of parameter order and case
+func (f *HostMapFilter) Key() string {
+	q := url.Values{}
+	if len(f.Gpus) > 0 {
+		q.Set("gpu", strings.Join(f.Gpus, ","))
+	}
+	if len(f.Countries) > 0 {
+		q.Set("country", strings.Join(f.Countries, ","))
+	}
+	if f.MinTflops > 0 {
+		q.Set("min_tflops", strconv.FormatFloat(f.MinTflops, 'f', -1, 64))
+	}
+	if f.Top > 0 {
+		q.Set("top", strconv.Itoa(f.Top))
+	}
+	if f.Verified != nil {
+		q.Set("verified", strconv.FormatBool(*f.Verified))
+	}
+	if f.Datacenter != nil {
+		q.Set("datacenter", strconv.FormatBool(*f.Datacenter))
+	}
+	if f.Isp != "" {
+		q.Set("isp", f.Isp)
+	}
+	return q.Encode()
+}
+
+func (f *HostMapFilter) match(host *Host) bool {
+	if host.Tflops < f.MinTflops {
+		return false
+	}
+	if f.Verified != nil && host.Verified != *f.Verified {
+		return false
+	}
+	if f.Datacenter != nil && host.Datacenter != *f.Datacenter {
+		return false
+	}
+	if len(f.Countries) > 0 && !slices.Contains(f.Countries, strings.ToUpper(host.Location.Country)) {
+		return false
+	}
+	if f.Isp != "" &&
+		!strings.Contains(strings.ToLower(host.Location.ISP), f.Isp) &&
+		!strings.Contains(strings.ToLower(host.Location.Organization), f.Isp) {
+		return false
+	}
+	if len(f.Gpus) > 0 {
+		found := false
+		for name := range host.Gpus {
+			if slices.Contains(f.Gpus, strings.ToLower(name)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// HostMapQueries serves filtered host maps of a single update, computing them on demand
+type HostMapQueries struct {
+	hosts Hosts // hosts with known location, sorted by TFLOPS (largest first)
+	ts    time.Time
+	cache *LruCache[string, *CachedResponse]
+}
+
+func newHostMapQueries(machines VastAiMachineOffers, ts time.Time) *HostMapQueries {
+	hosts := machines.getHosts()
+	located := make(Hosts, 0, len(hosts))
+	for _, host := range hosts {
+		if host.Location != nil {
+			located = append(located, host)
+		}
+	}
+	return &HostMapQueries{
+		hosts: located,
+		ts:    ts,
+		cache: NewLruCache[string, *CachedResponse](settings().hostMapCacheSize),
+	}
+}
+
+func (q *HostMapQueries) Response(f *HostMapFilter) *CachedResponse {
+	key := f.Key()
+	if resp, found := q.cache.Get(key); found {
+		if metrics != nil {
+			metrics.ObserveHostMapCache(true)
+		}
+		return resp
+	}
+	if metrics != nil {
+		metrics.ObserveHostMapCache(false)
+	}
+
+	items := make(HostMapItems, 0)
+	for i := range q.hosts {
+		if f.Top > 0 && len(items) >= f.Top {
+			break
+		}
+		if f.match(&q.hosts[i]) {
+			items = append(items, *q.hosts[i].mapItem())
+		}
+	}
+
+	url := "/host-map-data"
+	if key != "" {
+		url += "?" + key
+	}
+	resp := items.serialize(url, q.ts, true)
+	q.cache.Put(key, resp)
+	return resp
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseHostMapFilter(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string // Key() of the parsed filter
+		wantErr bool
+	}{
+		{query: "", want: ""},
+		{query: "filter=all", want: ""},
+		{query: "filter=dc", want: "datacenter=true"},
+		{query: "gpu=RTX_4090,rtx_3090,RTX_4090&country=us,DE", want: "country=DE%2CUS&gpu=rtx+3090%2Crtx+4090"},
+		{query: "min_tflops=100&verified=1&isp=Hetzner", want: "isp=hetzner&min_tflops=100&verified=true"},
+		// explicit parameters override the preset regardless of their position
+		{query: "top=20&filter=top-100", want: "top=20"},
+		{query: "filter=top-100&top=20", want: "top=20"},
+		{query: "datacenter=false&filter=dc", want: "datacenter=false"},
+		// the last value wins
+		{query: "top=5&top=7", want: "top=7"},
+		// unknown parameters are ignored
+		{query: "top=5&_=1700000000&from=now-1h", want: "top=5"},
+		{query: "filter=nope", wantErr: true},
+		{query: "top=0", wantErr: true},
+		{query: "top=x", wantErr: true},
+		{query: "min_tflops=x", wantErr: true},
+		{query: "verified=maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parseHostMapFilter(query)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %q, want error", tt.query, f.Key())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if got := f.Key(); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
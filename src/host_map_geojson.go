@@ -0,0 +1,222 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+type GeoJsonFeatureCollection struct {
+	Type     string           `json:"type"`
+	Metadata GeoJsonMetadata  `json:"metadata"`
+	Features []GeoJsonFeature `json:"features"`
+}
+
+type GeoJsonMetadata struct {
+	Url       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+	Count     int       `json:"count"`
+	Notes     []string  `json:"notes,omitempty"`
+}
+
+type GeoJsonFeature struct {
+	Type       string          `json:"type"`
+	Id         string          `json:"id,omitempty"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties any             `json:"properties"`
+}
+
+type HostFeatureProperties struct {
+	HostId       int       `json:"host_id"`
+	MachineIds   string    `json:"machine_ids"`
+	Gpus         string    `json:"gpus"`
+	GpuCount     int       `json:"gpu_count"`
+	GpuModels    GpuCounts `json:"gpu_models"`
+	Tflops       float64   `json:"tflops"`
+	Datacenter   bool      `json:"datacenter"`
+	Country      string    `json:"country"`
+	Location     string    `json:"location"`
+	ISP          string    `json:"isp"`
+	Organization string    `json:"organization"`
+	InetUp       float64   `json:"inet_up"`   // Mb/s
+	InetDown     float64   `json:"inet_down"` // Mb/s
+	Connection   string    `json:"connection"`
+}
+
+type CountryFeatureProperties struct {
+	IsoCode        string    `json:"iso_code"`
+	Hosts          int       `json:"hosts"`
+	Gpus           int       `json:"gpus"`
+	DatacenterGpus int       `json:"datacenter_gpus"`
+	GpuModels      GpuCounts `json:"gpu_models"`
+	Tflops         float64   `json:"tflops"`
+}
+
+// country geometries from --geo-countries-file by ISO 3166-1 alpha-2 code
+var countryGeometries map[string]json.RawMessage
+
+// property names holding alpha-2 codes in common country datasets (Natural Earth and others), in order of preference
+var countryIsoProperties = []string{"ISO_A2_EH", "ISO_A2", "iso_a2", "ISO3166-1-Alpha-2", "iso_code"}
+
+func loadCountryGeometries(path string) (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fc struct {
+		Features []struct {
+			Id         any                        `json:"id"`
+			Geometry   json.RawMessage            `json:"geometry"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	result := make(map[string]json.RawMessage, len(fc.Features))
+	for _, f := range fc.Features {
+		code := ""
+		for _, name := range countryIsoProperties {
+			var s string
+			if json.Unmarshal(f.Properties[name], &s) == nil && len(s) == 2 {
+				code = s
+				break
+			}
+		}
+		if id, ok := f.Id.(string); ok && code == "" && len(id) == 2 {
+			code = id
+		}
+		if code != "" {
+			result[strings.ToUpper(code)] = f.Geometry
+		}
+	}
+	slog.Info("Loaded country geometries", "count", len(result), "path", path)
+	return result, nil
+}
+
+func (host *Host) geoJsonFeature() *GeoJsonFeature {
+	if host.Location == nil {
+		return nil
+	}
+	loc := host.Location
+	connection := ""
+	if host.InetDown > 0 && host.InetUp > 0 {
+		connection = fmt.Sprintf("↓ %.0f ↑ %.0f Mb/s", host.InetDown, host.InetUp)
+	}
+	gpuCount := 0
+	for _, count := range host.Gpus {
+		gpuCount += count
+	}
+	// GeoJSON positions are [longitude, latitude]
+	geometry := fmt.Sprintf(`{"type":"Point","coordinates":[%g,%g]}`, loc.Long, loc.Lat)
+	return &GeoJsonFeature{
+		Type:     "Feature",
+		Geometry: json.RawMessage(geometry),
+		Properties: HostFeatureProperties{
+			HostId:       host.HostId,
+			MachineIds:   intListToString(host.MachineIds),
+			Gpus:         host.Gpus.String(),
+			GpuCount:     gpuCount,
+			GpuModels:    host.Gpus,
+			Tflops:       host.Tflops,
+			Datacenter:   host.Datacenter,
+			Country:      loc.Country,
+			Location:     loc.Location,
+			ISP:          loc.ISP,
+			Organization: loc.Organization,
+			InetUp:       host.InetUp,
+			InetDown:     host.InetDown,
+			Connection:   connection,
+		},
+	}
+}
+
+func serializeHostMapGeoJson(machines VastAiMachineOffers, ts time.Time) (hostsResp, countriesResp *CachedResponse) {
+	defer timeStage("json_host_map_geojson")()
+
+	hosts := machines.getHosts()
+
+	hostFeatures := make([]GeoJsonFeature, 0, len(hosts))
+	countries := make(map[string]*CountryFeatureProperties)
+	for i := range hosts {
+		feature := hosts[i].geoJsonFeature()
+		if feature == nil {
+			continue
+		}
+		hostFeatures = append(hostFeatures, *feature)
+
+		props := feature.Properties.(HostFeatureProperties)
+		if props.Country == "" {
+			continue
+		}
+		c := countries[props.Country]
+		if c == nil {
+			c = &CountryFeatureProperties{IsoCode: props.Country, GpuModels: make(GpuCounts)}
+			countries[props.Country] = c
+		}
+		c.Hosts++
+		c.Gpus += props.GpuCount
+		if props.Datacenter {
+			c.DatacenterGpus += props.GpuCount
+		}
+		for name, count := range props.GpuModels {
+			c.GpuModels[name] += count
+		}
+		c.Tflops += props.Tflops
+	}
+
+	countryFeatures := make([]GeoJsonFeature, 0, len(countries))
+	for code, props := range countries {
+		geometry := countryGeometries[code]
+		if geometry == nil {
+			geometry = json.RawMessage("null")
+		}
+		countryFeatures = append(countryFeatures, GeoJsonFeature{
+			Type:       "Feature",
+			Id:         code,
+			Geometry:   geometry,
+			Properties: *props,
+		})
+	}
+	slices.SortFunc(countryFeatures, func(a, b GeoJsonFeature) int {
+		pa, pb := a.Properties.(CountryFeatureProperties), b.Properties.(CountryFeatureProperties)
+		if c := cmp.Compare(pb.Gpus, pa.Gpus); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	hostsResp = serializeGeoJson("/host-map.geojson", ts, hostFeatures, []string{
+		"Host points, sorted by total TFLOPS (largest first).",
+		"Hosts without geolocation are omitted.",
+	})
+	countriesResp = serializeGeoJson("/host-map-countries.geojson", ts, countryFeatures, []string{
+		"Country layer keyed by ISO 3166-1 alpha-2 code (feature id), sorted by number of GPUs.",
+		"Geometry is null unless --geo-countries-file is specified.",
+	})
+	return hostsResp, countriesResp
+}
+
+func serializeGeoJson(url string, ts time.Time, features []GeoJsonFeature, notes []string) *CachedResponse {
+	j, err := json.MarshalIndent(GeoJsonFeatureCollection{
+		Type: "FeatureCollection",
+		Metadata: GeoJsonMetadata{
+			Url:       url,
+			Timestamp: ts.UTC(),
+			Count:     len(features),
+			Notes:     notes,
+		},
+		Features: features,
+	}, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", url, "err", err)
+		return buildCachedResponse(ts, url, nil)
+	}
+	return buildCachedResponse(ts, url, j)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testGeoJson struct {
+	Metadata GeoJsonMetadata `json:"metadata"`
+	Features []struct {
+		Id       string `json:"id"`
+		Geometry *struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	} `json:"features"`
+}
+
+func parseTestGeoJson(t *testing.T, resp *CachedResponse) testGeoJson {
+	t.Helper()
+	var result testGeoJson
+	if err := json.Unmarshal(resp.raw, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSerializeHostMapGeoJson(t *testing.T) {
+	prevGeometries := countryGeometries
+	countryGeometries = map[string]json.RawMessage{"DE": json.RawMessage(`{"type":"Polygon","coordinates":[]}`)}
+	t.Cleanup(func() { countryGeometries = prevGeometries })
+
+	berlin := &GeoLocation{Country: "DE", Location: "Berlin", Lat: 52.52, Long: 13.405}
+	munich := &GeoLocation{Country: "DE", Location: "Munich", Lat: 48.137, Long: 11.575}
+	paris := &GeoLocation{Country: "FR", Location: "Paris", Lat: 48.857, Long: 2.352}
+	machines := VastAiMachineOffers{
+		{MachineId: 1, HostId: 1, GpuName: "RTX 4090", NumGpus: 4, Tflops: 400, Location: berlin},
+		{MachineId: 2, HostId: 1, GpuName: "RTX 3090", NumGpus: 2, Tflops: 70, Location: berlin},
+		{MachineId: 3, HostId: 2, GpuName: "H100 SXM", NumGpus: 8, Tflops: 800, Datacenter: true, Location: munich},
+		{MachineId: 4, HostId: 3, GpuName: "RTX 4090", NumGpus: 1, Tflops: 100, Location: paris},
+		// hosts without location are skipped
+		{MachineId: 5, HostId: 4, GpuName: "RTX 4090", NumGpus: 8, Tflops: 800},
+	}
+	hostsResp, countriesResp := serializeHostMapGeoJson(machines, time.Now())
+
+	hosts := parseTestGeoJson(t, hostsResp)
+	if hosts.Metadata.Count != 3 || len(hosts.Features) != 3 {
+		t.Fatalf("got %d host features, want 3", len(hosts.Features))
+	}
+	// sorted by TFLOPS, positions are [long, lat]
+	first := hosts.Features[0]
+	if first.Properties["location"] != "Munich" || first.Geometry.Type != "Point" {
+		t.Errorf("got %+v, want Munich first", first)
+	}
+	if c := first.Geometry.Coordinates; len(c) != 2 || c[0] != munich.Long || c[1] != munich.Lat {
+		t.Errorf("got coordinates %v, want [%v, %v]", c, munich.Long, munich.Lat)
+	}
+	if second := hosts.Features[1]; second.Properties["gpu_count"] != 6.0 || second.Properties["machine_ids"] != "1, 2" {
+		t.Errorf("got %+v, want merged host 1", second.Properties)
+	}
+
+	countries := parseTestGeoJson(t, countriesResp)
+	if len(countries.Features) != 2 {
+		t.Fatalf("got %d country features, want 2", len(countries.Features))
+	}
+	de, fr := countries.Features[0], countries.Features[1]
+	if de.Id != "DE" || de.Properties["hosts"] != 2.0 || de.Properties["gpus"] != 14.0 ||
+		de.Properties["datacenter_gpus"] != 8.0 || de.Properties["tflops"] != 1270.0 {
+		t.Errorf("got %s %+v", de.Id, de.Properties)
+	}
+	if models := de.Properties["gpu_models"].(map[string]any); models["RTX 4090"] != 4.0 || models["H100 SXM"] != 8.0 {
+		t.Errorf("got GPU models %v", models)
+	}
+	if de.Geometry == nil || de.Geometry.Type != "Polygon" {
+		t.Errorf("got geometry %+v of DE, want polygon", de.Geometry)
+	}
+	if fr.Id != "FR" || fr.Properties["gpus"] != 1.0 || fr.Geometry != nil {
+		t.Errorf("got %s %+v, want FR without geometry", fr.Id, fr)
+	}
+}
+
+func TestLoadCountryGeometries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.geojson")
+	data := `{"type": "FeatureCollection", "features": [
+		{"geometry": {"type": "Polygon", "coordinates": [[[1, 1]]]}, "properties": {"ISO_A2": "-99", "ISO_A2_EH": "FR"}},
+		{"geometry": {"type": "Polygon", "coordinates": [[[2, 2]]]}, "properties": {"ISO_A2": "de", "iso_code": "XX"}},
+		{"id": "NL", "geometry": {"type": "Polygon", "coordinates": [[[3, 3]]]}, "properties": {"name": "Netherlands"}},
+		{"id": 528, "geometry": null, "properties": {"name": "no code"}}
+	]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	geometries, err := loadCountryGeometries(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		// Natural Earth has -99 in ISO_A2 for some countries, ISO_A2_EH is preferred
+		"FR": `{"type": "Polygon", "coordinates": [[[1, 1]]]}`,
+		// codes are upper-cased, earlier properties take precedence
+		"DE": `{"type": "Polygon", "coordinates": [[[2, 2]]]}`,
+		// feature id is the fallback
+		"NL": `{"type": "Polygon", "coordinates": [[[3, 3]]]}`,
+	}
+	if len(geometries) != len(want) {
+		t.Errorf("got %d geometries, want %d", len(geometries), len(want))
+	}
+	for code, geometry := range want {
+		if string(geometries[code]) != geometry {
+			t.Errorf("%s: got %s, want %s", code, geometries[code], geometry)
+		}
+	}
+}
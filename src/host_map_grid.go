@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultGridPrecision = 3 // cells of ~156 x 156 km
+	maxGridPrecision     = 8
+)
+
+type HostMapGridCell struct {
+	Geohash    string     `json:"geohash"`
+	Lat        float64    `json:"lat"`  // centroid of hosts weighted by TFLOPS
+	Long       float64    `json:"long"` // centroid of hosts weighted by TFLOPS
+	Bounds     [4]float64 `json:"bounds"`
+	Hosts      int        `json:"hosts"`
+	Gpus       int        `json:"gpus"`
+	GpuModels  GpuCounts  `json:"gpu_models"`
+	Tflops     float64    `json:"tflops"`
+	TflopsSqrt float64    `json:"tflops_sqrt"`
+}
+
+type HostMapGridResponse struct {
+	Url       string            `json:"url"`
+	Timestamp time.Time         `json:"timestamp"`
+	Precision int               `json:"precision"`
+	Count     int               `json:"count"`
+	Notes     []string          `json:"notes"`
+	Cells     []HostMapGridCell `json:"cells"`
+}
+
+// parseGridQuery splits ?precision= from host map filters
+func parseGridQuery(query url.Values) (int, *HostMapFilter, error) {
+	precision := defaultGridPrecision
+	if query.Has("precision") {
+		var err error
+		precision, err = strconv.Atoi(query.Get("precision"))
+		if err != nil || precision < 1 || precision > maxGridPrecision {
+			return 0, nil, fmt.Errorf("invalid precision: expected 1 to %d", maxGridPrecision)
+		}
+		query = maps.Clone(query)
+		query.Del("precision")
+	}
+	f, err := parseHostMapFilter(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	return precision, f, nil
+}
+
+func (q *HostMapQueries) GridResponse(precision int, f *HostMapFilter) *CachedResponse {
+	key := f.Key()
+	params := "precision=" + strconv.Itoa(precision)
+	if key != "" {
+		params += "&" + key
+	}
+	endpoint := "/host-map-data/grid?" + params
+
+	if resp, found := q.cache.Get(endpoint); found {
+		if metrics != nil {
+			metrics.ObserveHostMapCache(true)
+		}
+		return resp
+	}
+	if metrics != nil {
+		metrics.ObserveHostMapCache(false)
+	}
+
+	cells := make(map[string]*HostMapGridCell)
+	// sums of coordinates and weights for centroids
+	type centroid struct{ lat, long, weight float64 }
+	centroids := make(map[string]*centroid)
+	matched := 0
+	for i := range q.hosts {
+		host := &q.hosts[i]
+		if f.Top > 0 && matched >= f.Top {
+			break
+		}
+		if !f.match(host) {
+			continue
+		}
+		matched++
+
+		hash, bounds := geohashEncode(host.Location.Lat, host.Location.Long, precision)
+		cell := cells[hash]
+		if cell == nil {
+			cell = &HostMapGridCell{Geohash: hash, Bounds: bounds, GpuModels: make(GpuCounts)}
+			cells[hash] = cell
+			centroids[hash] = &centroid{}
+		}
+		cell.Hosts++
+		for name, count := range host.Gpus {
+			cell.GpuModels[name] += count
+			cell.Gpus += count
+		}
+		cell.Tflops += host.Tflops
+
+		// hosts with unknown performance still count
+		weight := max(host.Tflops, 1)
+		c := centroids[hash]
+		c.lat += host.Location.Lat * weight
+		c.long += host.Location.Long * weight
+		c.weight += weight
+	}
+
+	result := HostMapGridResponse{
+		Url:       endpoint,
+		Timestamp: q.ts.UTC(),
+		Precision: precision,
+		Count:     len(cells),
+		Notes: []string{
+			"Hosts are grouped by geohash of the given precision (1 to 8), sorted by total TFLOPS (largest first).",
+			"Lat and long are centroids of hosts weighted by TFLOPS, bounds are [min_lat, min_long, max_lat, max_long] of the cell.",
+			"Filters of /host-map-data can be applied as well.",
+		},
+		Cells: make([]HostMapGridCell, 0, len(cells)),
+	}
+	for hash, cell := range cells {
+		c := centroids[hash]
+		cell.Lat = c.lat / c.weight
+		cell.Long = c.long / c.weight
+		cell.TflopsSqrt = math.Sqrt(cell.Tflops)
+		result.Cells = append(result.Cells, *cell)
+	}
+	slices.SortFunc(result.Cells, func(a, b HostMapGridCell) int {
+		if c := cmp.Compare(b.Tflops, a.Tflops); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Geohash, b.Geohash)
+	})
+
+	j, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", endpoint, "err", err)
+		return buildCachedResponse(q.ts, endpoint, nil)
+	}
+	resp := buildCachedResponse(q.ts, endpoint, j)
+	q.cache.Put(endpoint, resp)
+	return resp
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohashEncode returns geohash of the point and bounds of its cell: [min_lat, min_long, max_lat, max_long]
+func geohashEncode(lat, long float64, precision int) (string, [4]float64) {
+	minLat, maxLat := -90.0, 90.0
+	minLong, maxLong := -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	even := true
+	bit, ch := 0, 0
+	for len(hash) < precision {
+		// bits alternate between longitude and latitude, starting with longitude
+		if even {
+			mid := (minLong + maxLong) / 2
+			if long >= mid {
+				ch |= 1 << (4 - bit)
+				minLong = mid
+			} else {
+				maxLong = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch |= 1 << (4 - bit)
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+		if bit < 4 {
+			bit++
+		} else {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash), [4]float64{minLat, minLong, maxLat, maxLong}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGeohashEncode(t *testing.T) {
+	tests := []struct {
+		lat, long float64
+		precision int
+		want      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{42.6, -5.6, 5, "ezs42"},
+		{50.4777, 12.3649, 3, "u2b"},
+		{-33.8688, 151.2093, 4, "r3gx"},
+		{0, 0, 1, "s"},
+		{-90, -180, 2, "00"},
+		{90, 180, 2, "zz"},
+	}
+	for _, tt := range tests {
+		hash, bounds := geohashEncode(tt.lat, tt.long, tt.precision)
+		if hash != tt.want {
+			t.Errorf("geohashEncode(%v, %v, %d) = %s, want %s", tt.lat, tt.long, tt.precision, hash, tt.want)
+		}
+		minLat, minLong, maxLat, maxLong := bounds[0], bounds[1], bounds[2], bounds[3]
+		if tt.lat < minLat || tt.lat > maxLat || tt.long < minLong || tt.long > maxLong {
+			t.Errorf("geohashEncode(%v, %v, %d): point is outside of bounds %v", tt.lat, tt.long, tt.precision, bounds)
+		}
+	}
+}
+
+func TestGridResponse(t *testing.T) {
+	q := &HostMapQueries{
+		hosts: Hosts{
+			{HostId: 1, Tflops: 300, Gpus: GpuCounts{"RTX 4090": 8}, Location: &GeoLocation{Lat: 50.47, Long: 12.36}},
+			{HostId: 2, Tflops: 100, Gpus: GpuCounts{"RTX 3090": 2}, Location: &GeoLocation{Lat: 50.11, Long: 12.01}},
+			{HostId: 3, Tflops: 0, Gpus: GpuCounts{"RTX 3090": 1}, Location: &GeoLocation{Lat: 40.71, Long: -74.0}},
+		},
+		ts:    time.Now(),
+		cache: NewLruCache[string, *CachedResponse](4),
+	}
+
+	resp := q.GridResponse(2, &HostMapFilter{})
+	var grid HostMapGridResponse
+	if err := json.Unmarshal(resp.raw, &grid); err != nil {
+		t.Fatal(err)
+	}
+	if grid.Count != 2 || len(grid.Cells) != 2 {
+		t.Fatalf("got %d cells, want 2: %+v", len(grid.Cells), grid.Cells)
+	}
+
+	// sorted by TFLOPS
+	de := grid.Cells[0]
+	if de.Geohash != "u2" || de.Hosts != 2 || de.Gpus != 10 || de.Tflops != 400 || de.GpuModels["RTX 3090"] != 2 {
+		t.Errorf("got %+v", de)
+	}
+	// centroid is weighted by TFLOPS
+	wantLat := (50.47*300 + 50.11*100) / 400
+	if diff := de.Lat - wantLat; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("got centroid lat %v, want %v", de.Lat, wantLat)
+	}
+	// hosts with unknown TFLOPS count with weight 1
+	us := grid.Cells[1]
+	if us.Geohash != "dr" || us.Hosts != 1 || us.Lat != 40.71 || us.Long != -74.0 {
+		t.Errorf("got %+v", us)
+	}
+
+	if cached := q.GridResponse(2, &HostMapFilter{}); cached != resp {
+		t.Error("second request was not served from cache")
+	}
+}
@@ -11,15 +11,18 @@ import (
 type GpuCounts map[string]int
 
 type Host struct {
-	HostId      int          `json:"host_id"`
-	MachineIds  []int        `json:"machine_ids"`
-	IpAddresses []string     `json:"ip_addresses"`
-	Gpus        GpuCounts    `json:"gpus"`
-	Tflops      float64      `json:"tflops"`
-	Datacenter  bool         `json:"datacenter"`
-	Location    *GeoLocation `json:"location,omitempty"`
-	InetUp      float64      `json:"inet_up,omitempty"`
-	InetDown    float64      `json:"inet_down,omitempty"`
+	HostId          int          `json:"host_id"`
+	MachineIds      []int        `json:"machine_ids"`
+	IpAddresses     []string     `json:"ip_addresses"`
+	Gpus            GpuCounts    `json:"gpus"`
+	Tflops          float64      `json:"tflops"`
+	Datacenter      bool         `json:"datacenter"`
+	Verified        bool         `json:"verified"`
+	HostClass       HostClass    `json:"host_class"`
+	ClassConfidence float64      `json:"host_class_confidence"`
+	Location        *GeoLocation `json:"location,omitempty"`
+	InetUp          float64      `json:"inet_up,omitempty"`
+	InetDown        float64      `json:"inet_down,omitempty"`
 }
 
 type Hosts []Host
@@ -41,15 +44,18 @@ func (machines VastAiMachineOffers) getHosts() Hosts {
 		gpus[m.GpuName] = m.NumGpus
 
 		item := Host{
-			HostId:      m.HostId,
-			MachineIds:  []int{m.MachineId},
-			IpAddresses: []string{m.IpAddr},
-			Gpus:        gpus,
-			Tflops:      m.Tflops,
-			Datacenter:  m.Datacenter,
-			InetUp:      m.InetUp,
-			InetDown:    m.InetDown,
-			Location:    m.Location,
+			HostId:          m.HostId,
+			MachineIds:      []int{m.MachineId},
+			IpAddresses:     []string{m.IpAddr},
+			Gpus:            gpus,
+			Tflops:          m.Tflops,
+			Datacenter:      m.Datacenter,
+			Verified:        m.Verified,
+			HostClass:       m.HostClass,
+			ClassConfidence: m.ClassConfidence,
+			InetUp:          m.InetUp,
+			InetDown:        m.InetDown,
+			Location:        m.Location,
 		}
 
 		k := item.mergeKey()
@@ -97,14 +103,17 @@ func (item1 Host) merge(item2 Host) Host {
 		gpus[name] += count
 	}
 	return Host{
-		HostId:      item2.HostId,
-		MachineIds:  append(item1.MachineIds, item2.MachineIds...),
-		IpAddresses: append(item1.IpAddresses, item2.IpAddresses...),
-		Gpus:        gpus,
-		Tflops:      item1.Tflops + item2.Tflops,
-		Datacenter:  item1.Datacenter || item2.Datacenter,
-		Location:    item2.Location,
-		InetUp:      max(item1.InetUp, item2.InetUp),
-		InetDown:    max(item1.InetDown, item2.InetDown),
+		HostId:          item2.HostId,
+		MachineIds:      append(item1.MachineIds, item2.MachineIds...),
+		IpAddresses:     append(item1.IpAddresses, item2.IpAddresses...),
+		Gpus:            gpus,
+		Tflops:          item1.Tflops + item2.Tflops,
+		Datacenter:      item1.Datacenter || item2.Datacenter,
+		Verified:        item1.Verified || item2.Verified,
+		HostClass:       item2.HostClass,
+		ClassConfidence: item2.ClassConfidence,
+		Location:        item2.Location,
+		InetUp:          max(item1.InetUp, item2.InetUp),
+		InetDown:        max(item1.InetDown, item2.InetDown),
 	}
 }
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-set/v2"
+)
+
+// share of GPU model supply held by the largest N hosts
+var hostsTopLevels = []int{1, 5, 10, 20}
+
+// ranges of GPU counts per host
+var hostSizeRanges = []struct {
+	name string
+	max  int
+}{
+	{"1", 1},
+	{"2-3", 3},
+	{"4-7", 7},
+	{"8-15", 15},
+	{"16-63", 63},
+	{"64+", 1 << 30},
+}
+
+// ranges of rented fraction of GPUs per host, the first one which matches is used;
+// "0" and "100%" are exact, so a single free GPU of a large host still counts as partially rented
+var hostRentedRanges = []struct {
+	name  string
+	match func(fraction float64) bool
+}{
+	{"0", func(f float64) bool { return f == 0 }},
+	{"0-25%", func(f float64) bool { return f <= 0.25 }},
+	{"25-50%", func(f float64) bool { return f <= 0.5 }},
+	{"50-75%", func(f float64) bool { return f <= 0.75 }},
+	{"75-99%", func(f float64) bool { return f < 1 }},
+	{"100%", func(f float64) bool { return f == 1 }},
+}
+
+// max number of host ids listed in new/gone lists
+const hostsTurnoverListLimit = 100
+
+type HostsTopShare struct {
+	Top   int     `json:"top"`
+	Share float64 `json:"share"`
+}
+
+type HostsGpuModelStats struct {
+	Name      string          `json:"name"`
+	Gpus      int             `json:"gpus"`
+	Hosts     int             `json:"hosts"`
+	TopShares []HostsTopShare `json:"top_shares"`
+}
+
+type HostsRangeCount struct {
+	Range string `json:"range"`
+	Hosts int    `json:"hosts"`
+	Gpus  int    `json:"gpus"`
+}
+
+type HostsTurnover struct {
+	// false on the first snapshot after start
+	Known    bool  `json:"known"`
+	New      int   `json:"new"`
+	Gone     int   `json:"gone"`
+	NewIds   []int `json:"new_ids,omitempty"`
+	GoneIds  []int `json:"gone_ids,omitempty"`
+	Interval int   `json:"interval_seconds"`
+}
+
+type HostsStats struct {
+	Hosts              int                  `json:"hosts"`
+	Gpus               int                  `json:"gpus"`
+	MeanRentedFraction float64              `json:"mean_rented_fraction"`
+	SizeDistribution   []HostsRangeCount    `json:"size_distribution"`
+	RentedDistribution []HostsRangeCount    `json:"rented_distribution"`
+	GpuModels          []HostsGpuModelStats `json:"gpu_models"`
+	Turnover           HostsTurnover        `json:"turnover"`
+}
+
+type HostsStatsResponse struct {
+	Url       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+	Notes     []string  `json:"notes"`
+	*HostsStats
+}
+
+// remembers hosts of the previous snapshot to find new and gone ones
+type hostsTracker struct {
+	mu      sync.Mutex
+	hostIds *set.Set[int]
+	ts      time.Time
+}
+
+var hostTurnover hostsTracker
+
+func (machines VastAiMachineOffers) hostsStats(ts time.Time) *HostsStats {
+	defer timeStage("calc_hosts_stats")()
+
+	// hosts split by location are counted once
+	type hostTotals struct {
+		gpus   int
+		rented int
+		models GpuCounts
+	}
+	byId := make(map[int]*hostTotals)
+	for _, m := range machines {
+		h := byId[m.HostId]
+		if h == nil {
+			h = &hostTotals{models: make(GpuCounts)}
+			byId[m.HostId] = h
+		}
+		h.gpus += m.NumGpus
+		h.rented += m.NumGpusRented
+		h.models[m.GpuName] += m.NumGpus
+	}
+
+	result := &HostsStats{Hosts: len(byId)}
+	for _, r := range hostSizeRanges {
+		result.SizeDistribution = append(result.SizeDistribution, HostsRangeCount{Range: r.name})
+	}
+	for _, r := range hostRentedRanges {
+		result.RentedDistribution = append(result.RentedDistribution, HostsRangeCount{Range: r.name})
+	}
+
+	perModel := make(map[string][]int) // GPU counts per host
+	sumRentedFraction := 0.0
+	for _, h := range byId {
+		result.Gpus += h.gpus
+		for i, r := range hostSizeRanges {
+			if h.gpus <= r.max {
+				result.SizeDistribution[i].Hosts++
+				result.SizeDistribution[i].Gpus += h.gpus
+				break
+			}
+		}
+		if h.gpus > 0 {
+			fraction := float64(h.rented) / float64(h.gpus)
+			sumRentedFraction += fraction
+			for i, r := range hostRentedRanges {
+				if r.match(fraction) {
+					result.RentedDistribution[i].Hosts++
+					result.RentedDistribution[i].Gpus += h.gpus
+					break
+				}
+			}
+		}
+		for name, count := range h.models {
+			perModel[name] = append(perModel[name], count)
+		}
+	}
+	if result.Hosts > 0 {
+		result.MeanRentedFraction = sumRentedFraction / float64(result.Hosts)
+	}
+
+	for name, counts := range perModel {
+		slices.SortFunc(counts, func(a, b int) int { return cmp.Compare(b, a) })
+		total := 0
+		for _, c := range counts {
+			total += c
+		}
+		model := HostsGpuModelStats{Name: name, Gpus: total, Hosts: len(counts)}
+		for _, n := range hostsTopLevels {
+			top := 0
+			for _, c := range counts[:min(n, len(counts))] {
+				top += c
+			}
+			share := 0.0
+			if total > 0 {
+				share = float64(top) / float64(total)
+			}
+			model.TopShares = append(model.TopShares, HostsTopShare{Top: n, Share: share})
+		}
+		result.GpuModels = append(result.GpuModels, model)
+	}
+	slices.SortFunc(result.GpuModels, func(a, b HostsGpuModelStats) int {
+		if c := cmp.Compare(b.Gpus, a.Gpus); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	ids := set.New[int](len(byId))
+	for id := range byId {
+		ids.Insert(id)
+	}
+	result.Turnover = hostTurnover.update(ids, ts)
+
+	return result
+}
+
+func (t *hostsTracker) update(ids *set.Set[int], ts time.Time) HostsTurnover {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var result HostsTurnover
+	if t.hostIds != nil {
+		newIds := ids.Difference(t.hostIds).Slice()
+		goneIds := t.hostIds.Difference(ids).Slice()
+		slices.Sort(newIds)
+		slices.Sort(goneIds)
+		result = HostsTurnover{
+			Known:    true,
+			New:      len(newIds),
+			Gone:     len(goneIds),
+			NewIds:   newIds[:min(len(newIds), hostsTurnoverListLimit)],
+			GoneIds:  goneIds[:min(len(goneIds), hostsTurnoverListLimit)],
+			Interval: int(ts.Sub(t.ts).Seconds()),
+		}
+	}
+	t.hostIds = ids
+	t.ts = ts
+	return result
+}
+
+func (stats *HostsStats) serialize(ts time.Time) *CachedResponse {
+	defer timeStage("json_hosts_stats")()
+
+	j, err := json.MarshalIndent(HostsStatsResponse{
+		Url:       "/hosts/stats",
+		Timestamp: ts.UTC(),
+		Notes: []string{
+			"Hosts are counted by host id, GPU models are sorted by number of GPUs.",
+			"Top shares are fractions of GPUs of the model held by the largest " + intListToString(hostsTopLevels) + " hosts.",
+			"Turnover compares host ids with the previous snapshot, lists are limited to " + strconv.Itoa(hostsTurnoverListLimit) + " ids.",
+			"Hosts which temporarily unlist all machines show up as gone and then new.",
+		},
+		HostsStats: stats,
+	}, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", "/hosts/stats", "err", err)
+		return buildCachedResponse(ts, "/hosts/stats", nil)
+	}
+	return buildCachedResponse(ts, "/hosts/stats", j)
+}
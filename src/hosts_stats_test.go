@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHostsStatsRentedDistribution(t *testing.T) {
+	machines := VastAiMachineOffers{
+		{MachineId: 1, HostId: 1, GpuName: "RTX 4090", NumGpus: 4, NumGpusRented: 0},
+		{MachineId: 2, HostId: 2, GpuName: "RTX 4090", NumGpus: 4, NumGpusRented: 1},
+		{MachineId: 3, HostId: 3, GpuName: "RTX 4090", NumGpus: 4, NumGpusRented: 3},
+		// almost fully rented large host is not 100%
+		{MachineId: 4, HostId: 4, GpuName: "H100 SXM", NumGpus: 2000, NumGpusRented: 1999},
+		{MachineId: 5, HostId: 5, GpuName: "RTX 3090", NumGpus: 2, NumGpusRented: 2},
+		// machines of the same host are summed
+		{MachineId: 6, HostId: 6, GpuName: "RTX 3090", NumGpus: 2, NumGpusRented: 2},
+		{MachineId: 7, HostId: 6, GpuName: "RTX 3090", NumGpus: 2, NumGpusRented: 1},
+	}
+	stats := machines.hostsStats(time.Now())
+
+	want := map[string]int{"0": 1, "0-25%": 1, "25-50%": 0, "50-75%": 2, "75-99%": 1, "100%": 1}
+	for _, r := range stats.RentedDistribution {
+		if r.Hosts != want[r.Range] {
+			t.Errorf("range %s: got %d hosts, want %d", r.Range, r.Hosts, want[r.Range])
+		}
+	}
+	if len(stats.RentedDistribution) != len(want) {
+		t.Errorf("got %d ranges, want %d", len(stats.RentedDistribution), len(want))
+	}
+}
+
+func TestUpdateHostsStatsDeletesGoneModels(t *testing.T) {
+	e := newVastAiGlobalCollector()
+	e.UpdateHostsStats(&HostsStats{GpuModels: []HostsGpuModelStats{
+		{Name: "RTX 4090", TopShares: []HostsTopShare{{Top: 1, Share: 0.5}, {Top: 10, Share: 1}}},
+		{Name: "RTX 3090", TopShares: []HostsTopShare{{Top: 1, Share: 1}}},
+	}})
+	if n := testutil.CollectAndCount(e.hosts_top_share); n != 3 {
+		t.Fatalf("got %d series, want 3", n)
+	}
+
+	e.UpdateHostsStats(&HostsStats{GpuModels: []HostsGpuModelStats{
+		{Name: "RTX 4090", TopShares: []HostsTopShare{{Top: 1, Share: 0.4}, {Top: 10, Share: 1}}},
+	}})
+	if n := testutil.CollectAndCount(e.hosts_top_share); n != 2 {
+		t.Errorf("got %d series after model is gone, want 2", n)
+	}
+	if v := testutil.ToFloat64(e.hosts_top_share.WithLabelValues("RTX 4090", "1")); v != 0.4 {
+		t.Errorf("got top 1 share %v, want 0.4", v)
+	}
+}
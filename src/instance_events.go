@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const maxInstanceEvents = 1000
+
+// last seen times of tracked instances are saved at least this often, so instances destroyed
+// while the exporter was down are recorded with about the time they were last seen
+const instanceLastSeenSaveInterval = 5 * time.Minute
+
+type InstanceEvent struct {
+	Ts         time.Time `json:"ts"`
+	Event      string    `json:"event"` // created, running, stopped, outbid, destroyed
+	InstanceId int       `json:"instance_id"`
+	MachineId  int       `json:"machine_id"`
+	RentalType string    `json:"rental_type"`
+	GpuName    string    `json:"gpu_name"`
+	NumGpus    int       `json:"num_gpus"`
+	Duration   float64   `json:"duration,omitempty"` // in seconds, only for "destroyed"
+}
+
+type trackedInstance struct {
+	MachineId  int       `json:"machineId"`
+	RentalType string    `json:"rentalType"`
+	GpuName    string    `json:"gpuName"`
+	NumGpus    int       `json:"numGpus"`
+	Running    bool      `json:"running"`
+	Outbid     bool      `json:"outbid"`
+	StartDate  float64   `json:"startDate"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	LastOutbid time.Time `json:"lastOutbid"`
+}
+
+type InstanceEventLog struct {
+	mu        sync.Mutex
+	Events    []InstanceEvent          `json:"events"`
+	Instances map[int]*trackedInstance `json:"instances"`
+	loaded    bool
+	saved     time.Time
+	ts        time.Time
+}
+
+type InstanceEventsResponse struct {
+	Url       string          `json:"url"`
+	Timestamp time.Time       `json:"timestamp"`
+	Count     int             `json:"count"`
+	Notes     []string        `json:"notes,omitempty"`
+	Events    []InstanceEvent `json:"events"`
+}
+
+func loadInstanceEventLog() *InstanceEventLog {
+	l := &InstanceEventLog{
+		Instances: make(map[int]*trackedInstance),
+	}
+
+	j, err := os.ReadFile(instanceEventsFile())
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Could not read instance event log", "err", err)
+		}
+		return l
+	}
+	if err := json.Unmarshal(j, l); err != nil {
+		slog.Error("Could not read instance event log", "err", err)
+		return l
+	}
+	if l.Instances == nil {
+		l.Instances = make(map[int]*trackedInstance)
+	}
+	l.loaded = true
+
+	slog.Info("Loaded instance event log", "events", len(l.Events), "instances", len(l.Instances))
+	return l
+}
+
+// UpdateFrom compares the current instance list with the previous one and returns newly recorded events.
+// On the very first run (no state file yet) existing instances are recorded silently.
+func (l *InstanceEventLog) UpdateFrom(instances []VastAiInstance, isMyMachineId map[int]bool, now time.Time) []InstanceEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	silent := !l.loaded
+	l.loaded = true
+
+	var events []InstanceEvent
+	emit := func(event string, id int, t *trackedInstance, ts time.Time) *InstanceEvent {
+		events = append(events, InstanceEvent{
+			Ts:         ts,
+			Event:      event,
+			InstanceId: id,
+			MachineId:  t.MachineId,
+			RentalType: t.RentalType,
+			GpuName:    t.GpuName,
+			NumGpus:    t.NumGpus,
+		})
+		return &events[len(events)-1]
+	}
+
+	seen := make(map[int]bool, len(instances))
+	changed := false
+
+	for _, instance := range instances {
+		if !isMyMachineId[instance.MachineId] {
+			continue
+		}
+		seen[instance.Id] = true
+
+		rentalType := instance.rentalType()
+		running := instance.ActualStatus == "running"
+		outbid := rentalType != "ondemand" && instance.MinBid > instance.DphBase
+
+		t, found := l.Instances[instance.Id]
+		if !found {
+			t = &trackedInstance{
+				MachineId:  instance.MachineId,
+				RentalType: rentalType,
+				GpuName:    instance.GpuName,
+				NumGpus:    instance.NumGpus,
+				StartDate:  instance.StartDate,
+				FirstSeen:  now,
+			}
+			l.Instances[instance.Id] = t
+			changed = true
+			emit("created", instance.Id, t, now)
+		}
+		t.LastSeen = now
+
+		if running != t.Running {
+			if running {
+				emit("running", instance.Id, t, now)
+			} else if found {
+				emit("stopped", instance.Id, t, now)
+			}
+			t.Running = running
+			changed = true
+		}
+
+		if outbid != t.Outbid {
+			if outbid {
+				emit("outbid", instance.Id, t, now)
+				t.LastOutbid = now
+			}
+			t.Outbid = outbid
+			changed = true
+		}
+	}
+
+	for id, t := range l.Instances {
+		if !seen[id] {
+			// the instance is gone since it was last seen, which may be long ago after a restart
+			gone := t.LastSeen
+			if gone.IsZero() {
+				gone = now
+			}
+			ev := emit("destroyed", id, t, gone)
+			ev.Duration = t.duration(gone).Seconds()
+			delete(l.Instances, id)
+			changed = true
+		}
+	}
+
+	if silent {
+		events = nil
+	}
+
+	slices.SortStableFunc(events, func(a, b InstanceEvent) int { return a.InstanceId - b.InstanceId })
+	l.Events = append(l.Events, events...)
+	if len(l.Events) > maxInstanceEvents {
+		l.Events = slices.Clone(l.Events[len(l.Events)-maxInstanceEvents:])
+	}
+	l.ts = now
+
+	if changed || now.Sub(l.saved) >= instanceLastSeenSaveInterval {
+		l.save()
+		l.saved = now
+	}
+
+	return events
+}
+
+// LastOutbid returns the time the instance was last outbid, or zero time if it was never outbid while tracked.
+func (l *InstanceEventLog) LastOutbid(id int) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, ok := l.Instances[id]; ok {
+		return t.LastOutbid
+	}
+	return time.Time{}
+}
+
+func (t *trackedInstance) duration(now time.Time) time.Duration {
+	start := t.FirstSeen
+	if t.StartDate > 0 {
+		start = time.Unix(int64(t.StartDate), 0)
+	}
+	return now.Sub(start)
+}
+
+// Response returns the event log (newest first) ready to be served with jsonHandler.
+func (l *InstanceEventLog) Response() *CachedResponse {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := slices.Clone(l.Events)
+	slices.Reverse(events)
+
+	j, err := json.MarshalIndent(InstanceEventsResponse{
+		Url:       "/my/events",
+		Timestamp: l.ts.UTC(),
+		Count:     len(events),
+		Notes: []string{
+			"Sorted from newest to oldest.",
+			"Duration is in seconds and is set for destroyed instances only.",
+		},
+		Events: events,
+	}, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", "/my/events", "err", err)
+		return nil
+	}
+
+	return &CachedResponse{ts: l.ts, etag: makeEtag(l.ts, "/my/events"), raw: j}
+}
+
+func (l *InstanceEventLog) save() {
+	j, err := json.Marshal(l)
+	if err != nil {
+		slog.Error("Could not store instance event log", "err", err)
+		return
+	}
+	err = writeFileAtomic(instanceEventsFile(), j, 0600)
+	if err != nil {
+		slog.Error("Could not store instance event log", "err", err)
+	}
+}
+
+func instanceEventsFile() string {
+	return *stateDir + "/.vastai_instance_events"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func eventNames(events []InstanceEvent) []string {
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev.Event)
+	}
+	return names
+}
+
+func TestInstanceEventLogTransitions(t *testing.T) {
+	prevStateDir := *stateDir
+	*stateDir = t.TempDir()
+	t.Cleanup(func() { *stateDir = prevStateDir })
+
+	l := loadInstanceEventLog()
+	mine := map[int]bool{1: true}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bundle := 1
+
+	// instances which exist before the first run are recorded silently
+	existing := VastAiInstance{Id: 10, MachineId: 1, ActualStatus: "running", BundleId: &bundle, NumGpus: 1, GpuName: "RTX 4090"}
+	if events := l.UpdateFrom([]VastAiInstance{existing}, mine, start); len(events) != 0 {
+		t.Fatalf("got %v on the first run, want none", events)
+	}
+
+	now := start.Add(time.Minute)
+	bid := VastAiInstance{Id: 11, MachineId: 1, ActualStatus: "loading", BundleId: &bundle, IsBid: true, DphBase: 0.5, MinBid: 0.2, NumGpus: 2, GpuName: "RTX 4090"}
+	other := VastAiInstance{Id: 20, MachineId: 2, ActualStatus: "running", BundleId: &bundle}
+	events := l.UpdateFrom([]VastAiInstance{existing, bid, other}, mine, now)
+	if got := eventNames(events); !slices.Equal(got, []string{"created"}) {
+		t.Fatalf("got %v, want created", got)
+	}
+	if ev := events[0]; ev.InstanceId != 11 || ev.RentalType != "bid" || ev.NumGpus != 2 || !ev.Ts.Equal(now) {
+		t.Errorf("got %+v", ev)
+	}
+
+	now = now.Add(time.Minute)
+	bid.ActualStatus = "running"
+	if got := eventNames(l.UpdateFrom([]VastAiInstance{existing, bid}, mine, now)); !slices.Equal(got, []string{"running"}) {
+		t.Errorf("got %v, want running", got)
+	}
+
+	now = now.Add(time.Minute)
+	bid.ActualStatus = "stopped"
+	bid.MinBid = 0.6
+	if got := eventNames(l.UpdateFrom([]VastAiInstance{existing, bid}, mine, now)); !slices.Equal(got, []string{"stopped", "outbid"}) {
+		t.Errorf("got %v, want stopped and outbid", got)
+	}
+	if got := l.LastOutbid(11); !got.Equal(now) {
+		t.Errorf("got last outbid %v, want %v", got, now)
+	}
+
+	now = now.Add(time.Minute)
+	events = l.UpdateFrom([]VastAiInstance{bid}, mine, now)
+	if got := eventNames(events); !slices.Equal(got, []string{"destroyed"}) {
+		t.Fatalf("got %v, want destroyed", got)
+	}
+	// destroyed at the previous update, when the instance was last seen
+	if ev := events[0]; ev.InstanceId != 10 || !ev.Ts.Equal(now.Add(-time.Minute)) || ev.Duration != 3*60 {
+		t.Errorf("got %+v", ev)
+	}
+	if len(l.Events) != 5 {
+		t.Errorf("got %d events in the log, want 5", len(l.Events))
+	}
+}
+
+func TestInstanceEventLogRestore(t *testing.T) {
+	prevStateDir := *stateDir
+	*stateDir = t.TempDir()
+	t.Cleanup(func() { *stateDir = prevStateDir })
+
+	mine := map[int]bool{1: true}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	bundle := 1
+	a := VastAiInstance{Id: 10, MachineId: 1, ActualStatus: "running", BundleId: &bundle, StartDate: float64(start.Unix())}
+	b := VastAiInstance{Id: 11, MachineId: 1, ActualStatus: "running", BundleId: &bundle}
+
+	l := loadInstanceEventLog()
+	l.UpdateFrom([]VastAiInstance{a}, mine, start)
+	l.UpdateFrom([]VastAiInstance{a, b}, mine, start.Add(time.Minute))
+	// last seen times are saved periodically even without changes
+	lastSeen := start.Add(10 * time.Minute)
+	l.UpdateFrom([]VastAiInstance{a, b}, mine, lastSeen)
+
+	// the exporter is down for an hour, meanwhile instance 10 is destroyed
+	restored := loadInstanceEventLog()
+	if len(restored.Instances) != 2 || len(restored.Events) != 2 {
+		t.Fatalf("got %d instances and %d events, want 2 and 2", len(restored.Instances), len(restored.Events))
+	}
+	events := restored.UpdateFrom([]VastAiInstance{b}, mine, lastSeen.Add(time.Hour))
+	if got := eventNames(events); !slices.Equal(got, []string{"destroyed"}) {
+		t.Fatalf("got %v, want destroyed", got)
+	}
+	if ev := events[0]; ev.InstanceId != 10 || !ev.Ts.Equal(lastSeen) || ev.Duration != (10*time.Minute).Seconds() {
+		t.Errorf("got %+v, want destroyed when last seen", ev)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// providers beyond top N are summed into "other" in metrics to keep cardinality bounded
+const ispMetricsTopN = 20
+
+type IspStatsProvider struct {
+	Name           string    `json:"name"`
+	Hosts          int       `json:"hosts"`
+	Gpus           int       `json:"gpus"`
+	GpuShare       float64   `json:"gpu_share"`
+	Tflops         float64   `json:"tflops"`
+	TflopsShare    float64   `json:"tflops_share"`
+	DatacenterGpus int       `json:"datacenter_gpus"`
+	GpuModels      GpuCounts `json:"gpu_models"`
+}
+
+// IspStatsGroup is a breakdown of GPU supply by one attribute of geolocation (ISP, ASN or organization)
+type IspStatsGroup struct {
+	Providers []IspStatsProvider `json:"providers"`
+	// Herfindahl–Hirschman index over shares in percent: 0..10000, above 2500 is highly concentrated
+	HhiGpus   float64 `json:"hhi_gpus"`
+	HhiTflops float64 `json:"hhi_tflops"`
+}
+
+type IspStats struct {
+	LocatedGpus   int           `json:"located_gpus"`
+	UnlocatedGpus int           `json:"unlocated_gpus"`
+	Isp           IspStatsGroup `json:"isp"`
+	Asn           IspStatsGroup `json:"asn"`
+	Organization  IspStatsGroup `json:"organization"`
+}
+
+type IspStatsResponse struct {
+	Url       string    `json:"url"`
+	Timestamp time.Time `json:"timestamp"`
+	Notes     []string  `json:"notes"`
+	IspStats
+}
+
+func (machines VastAiMachineOffers) ispStats() IspStats {
+	hosts := machines.getHosts()
+
+	var result IspStats
+	byIsp := make(map[string]*IspStatsProvider)
+	byAsn := make(map[string]*IspStatsProvider)
+	byOrg := make(map[string]*IspStatsProvider)
+	for i := range hosts {
+		host := &hosts[i]
+		gpus := 0
+		for _, count := range host.Gpus {
+			gpus += count
+		}
+		if host.Location == nil {
+			result.UnlocatedGpus += gpus
+			continue
+		}
+		result.LocatedGpus += gpus
+
+		isp := host.Location.ISP
+		org := host.Location.Organization
+		if org == "" {
+			// MaxMind omits organization when it is the same as ISP
+			org = isp
+		}
+		asn := ""
+		if host.Location.ASN != 0 {
+			asn = "AS" + strconv.FormatUint(uint64(host.Location.ASN), 10)
+		}
+		addIspStats(byIsp, isp, host, gpus)
+		addIspStats(byAsn, asn, host, gpus)
+		addIspStats(byOrg, org, host, gpus)
+	}
+
+	result.Isp = makeIspStatsGroup(byIsp)
+	result.Asn = makeIspStatsGroup(byAsn)
+	result.Organization = makeIspStatsGroup(byOrg)
+	return result
+}
+
+func addIspStats(m map[string]*IspStatsProvider, name string, host *Host, gpus int) {
+	if name == "" {
+		name = "unknown"
+	}
+	p := m[name]
+	if p == nil {
+		p = &IspStatsProvider{Name: name, GpuModels: make(GpuCounts)}
+		m[name] = p
+	}
+	p.Hosts++
+	p.Gpus += gpus
+	p.Tflops += host.Tflops
+	if host.Datacenter {
+		p.DatacenterGpus += gpus
+	}
+	for model, count := range host.Gpus {
+		p.GpuModels[model] += count
+	}
+}
+
+func makeIspStatsGroup(m map[string]*IspStatsProvider) IspStatsGroup {
+	totalGpus, totalTflops := 0, 0.0
+	for _, p := range m {
+		totalGpus += p.Gpus
+		totalTflops += p.Tflops
+	}
+
+	group := IspStatsGroup{Providers: make([]IspStatsProvider, 0, len(m))}
+	for _, p := range m {
+		if totalGpus > 0 {
+			p.GpuShare = float64(p.Gpus) / float64(totalGpus)
+		}
+		if totalTflops > 0 {
+			p.TflopsShare = p.Tflops / totalTflops
+		}
+		group.HhiGpus += (p.GpuShare * 100) * (p.GpuShare * 100)
+		group.HhiTflops += (p.TflopsShare * 100) * (p.TflopsShare * 100)
+		group.Providers = append(group.Providers, *p)
+	}
+	slices.SortFunc(group.Providers, func(a, b IspStatsProvider) int {
+		if c := cmp.Compare(b.Gpus, a.Gpus); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return group
+}
+
+// topWithOther returns top N providers and the sum of the rest (nil if there is none)
+func (group *IspStatsGroup) topWithOther(n int) ([]IspStatsProvider, *IspStatsProvider) {
+	if len(group.Providers) <= n {
+		return group.Providers, nil
+	}
+	other := IspStatsProvider{Name: "other"}
+	for _, p := range group.Providers[n:] {
+		other.Hosts += p.Hosts
+		other.Gpus += p.Gpus
+		other.GpuShare += p.GpuShare
+		other.Tflops += p.Tflops
+		other.TflopsShare += p.TflopsShare
+		other.DatacenterGpus += p.DatacenterGpus
+	}
+	return group.Providers[:n], &other
+}
+
+func serializeIspStats(stats IspStats, ts time.Time) *CachedResponse {
+	defer timeStage("json_isp_stats")()
+
+	j, err := json.MarshalIndent(IspStatsResponse{
+		Url:       "/isp-stats",
+		Timestamp: ts.UTC(),
+		Notes: []string{
+			"Providers are sorted by number of GPUs, shares are fractions of GPUs/TFLOPS of hosts with known location.",
+			"Organization falls back to ISP if the geolocation provider does not distinguish them.",
+			"ASN is only known from providers which return it (MaxMind, GeoIP ASN/ISP databases, ip-api.com), otherwise it is \"unknown\".",
+			"HHI is the Herfindahl-Hirschman index over shares in percent: below 1500 unconcentrated, above 2500 highly concentrated.",
+		},
+		IspStats: stats,
+	}, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", "/isp-stats", "err", err)
+		return buildCachedResponse(ts, "/isp-stats", nil)
+	}
+	return buildCachedResponse(ts, "/isp-stats", j)
+}
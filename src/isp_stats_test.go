@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMakeIspStatsGroup(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers map[string]*IspStatsProvider
+		wantOrder []string
+		wantHhi   float64
+	}{
+		{
+			name:    "empty",
+			wantHhi: 0,
+		},
+		{
+			name:      "monopoly",
+			providers: map[string]*IspStatsProvider{"Hetzner": {Name: "Hetzner", Gpus: 8, Tflops: 100}},
+			wantOrder: []string{"Hetzner"},
+			wantHhi:   10000,
+		},
+		{
+			name: "60/40",
+			providers: map[string]*IspStatsProvider{
+				"Comcast": {Name: "Comcast", Gpus: 4, Tflops: 40},
+				"Hetzner": {Name: "Hetzner", Gpus: 6, Tflops: 60},
+			},
+			wantOrder: []string{"Hetzner", "Comcast"},
+			wantHhi:   60*60 + 40*40,
+		},
+		{
+			name: "ties are sorted by name",
+			providers: map[string]*IspStatsProvider{
+				"b": {Name: "b", Gpus: 1, Tflops: 1},
+				"a": {Name: "a", Gpus: 1, Tflops: 1},
+				"c": {Name: "c", Gpus: 1, Tflops: 1},
+				"d": {Name: "d", Gpus: 1, Tflops: 1},
+			},
+			wantOrder: []string{"a", "b", "c", "d"},
+			wantHhi:   4 * 25 * 25,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := makeIspStatsGroup(tt.providers)
+			if len(group.Providers) != len(tt.wantOrder) {
+				t.Fatalf("got %d providers, want %d", len(group.Providers), len(tt.wantOrder))
+			}
+			for i, name := range tt.wantOrder {
+				if group.Providers[i].Name != name {
+					t.Errorf("provider %d: got %s, want %s", i, group.Providers[i].Name, name)
+				}
+			}
+			if math.Abs(group.HhiGpus-tt.wantHhi) > 1e-6 || math.Abs(group.HhiTflops-tt.wantHhi) > 1e-6 {
+				t.Errorf("got HHI %v (GPUs), %v (TFLOPS), want %v", group.HhiGpus, group.HhiTflops, tt.wantHhi)
+			}
+		})
+	}
+}
+
+func TestIspStatsTopWithOther(t *testing.T) {
+	group := makeIspStatsGroup(map[string]*IspStatsProvider{
+		"a": {Name: "a", Gpus: 5, Tflops: 50, DatacenterGpus: 5},
+		"b": {Name: "b", Gpus: 3, Tflops: 30},
+		"c": {Name: "c", Gpus: 2, Tflops: 20, DatacenterGpus: 1},
+	})
+
+	top, other := group.topWithOther(3)
+	if len(top) != 3 || other != nil {
+		t.Errorf("got %d top and %v other, want 3 and nil", len(top), other)
+	}
+
+	top, other = group.topWithOther(1)
+	if len(top) != 1 || top[0].Name != "a" {
+		t.Fatalf("got top %+v", top)
+	}
+	if other == nil || other.Name != "other" || other.Gpus != 5 || other.DatacenterGpus != 1 ||
+		math.Abs(other.GpuShare-0.5) > 1e-9 || math.Abs(other.TflopsShare-0.5) > 1e-9 {
+		t.Errorf("got other %+v", other)
+	}
+}
+
+func TestIspStats(t *testing.T) {
+	hetzner := &GeoLocation{ISP: "Hetzner Online GmbH", ASN: 24940}
+	comcast := &GeoLocation{ISP: "Comcast Cable Communications, LLC", Organization: "Comcast Business"}
+	machines := VastAiMachineOffers{
+		{MachineId: 1, HostId: 1, GpuName: "RTX 4090", NumGpus: 4, Location: hetzner},
+		{MachineId: 2, HostId: 2, GpuName: "RTX 3090", NumGpus: 2, Location: comcast},
+		{MachineId: 3, HostId: 3, GpuName: "RTX 3090", NumGpus: 2},
+	}
+	stats := machines.ispStats()
+	if stats.LocatedGpus != 6 || stats.UnlocatedGpus != 2 {
+		t.Errorf("got located %d, unlocated %d, want 6, 2", stats.LocatedGpus, stats.UnlocatedGpus)
+	}
+	if len(stats.Isp.Providers) != 2 || stats.Isp.Providers[0].Name != "Hetzner Online GmbH" {
+		t.Fatalf("got ISPs %+v", stats.Isp.Providers)
+	}
+	// organization falls back to ISP
+	orgs := map[string]int{}
+	for _, p := range stats.Organization.Providers {
+		orgs[p.Name] = p.Gpus
+	}
+	if orgs["Hetzner Online GmbH"] != 4 || orgs["Comcast Business"] != 2 {
+		t.Errorf("got organizations %v", orgs)
+	}
+	// ASN is unknown if the provider does not return it
+	asns := map[string]int{}
+	for _, p := range stats.Asn.Providers {
+		asns[p.Name] = p.Gpus
+	}
+	if len(asns) != 2 || asns["AS24940"] != 4 || asns["unknown"] != 2 {
+		t.Errorf("got ASNs %v", asns)
+	}
+}
+
+func TestUpdateIspStatsDeletesGoneProviders(t *testing.T) {
+	e := newVastAiGlobalCollector()
+	machines := VastAiMachineOffers{
+		{MachineId: 1, HostId: 1, GpuName: "RTX 4090", NumGpus: 4, Location: &GeoLocation{ISP: "a", ASN: 1}},
+		{MachineId: 2, HostId: 2, GpuName: "RTX 4090", NumGpus: 2, Location: &GeoLocation{ISP: "b", ASN: 2}},
+	}
+	e.UpdateIspStats(machines.ispStats())
+	// 2 providers by ISP, ASN and organization
+	if n := testutil.CollectAndCount(e.isp_gpu_count); n != 6 {
+		t.Fatalf("got %d series, want 6", n)
+	}
+
+	e.UpdateIspStats(machines[:1].ispStats())
+	if n := testutil.CollectAndCount(e.isp_gpu_count); n != 3 {
+		t.Errorf("got %d series after provider is gone, want 3", n)
+	}
+	if n := testutil.CollectAndCount(e.isp_hhi); n != 6 {
+		t.Errorf("got %d HHI series, want 6", n)
+	}
+
+	e.UpdateIspStats(IspStats{})
+	if n := testutil.CollectAndCount(e.isp_gpu_count); n != 0 {
+		t.Errorf("got %d series without located hosts, want 0", n)
+	}
+}
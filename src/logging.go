@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// repeated warnings of the same kind are logged at most logLimitBurst times per logLimitInterval
+const (
+	logLimitInterval = 10 * time.Minute
+	logLimitBurst    = 5
+)
+
+// logLevel can be changed at runtime by config reload
+var logLevel = new(slog.LevelVar)
+
+func setupLogging() error {
+	if err := applyLogLevel(); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch *logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid --log-format: %s", *logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func applyLogLevel() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevelName)); err != nil {
+		return fmt.Errorf("invalid --log-level: %s", *logLevelName)
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// fatal logs the error and exits, like log.Fatal
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+type logLimit struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// logLimiter counts messages by key within fixed windows
+type logLimiter struct {
+	mu     sync.Mutex
+	limits map[string]*logLimit
+}
+
+var warnLimiter = logLimiter{limits: make(map[string]*logLimit)}
+
+// allow tells if a message may be logged, and how many were suppressed in the previous window
+func (l *logLimiter) allow(key string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit := l.limits[key]
+	if limit == nil {
+		limit = &logLimit{start: now}
+		l.limits[key] = limit
+	}
+	suppressed := 0
+	if now.Sub(limit.start) >= logLimitInterval {
+		suppressed = limit.suppressed
+		*limit = logLimit{start: now}
+	}
+	if limit.count >= logLimitBurst {
+		limit.suppressed++
+		return false, 0
+	}
+	limit.count++
+	return true, suppressed
+}
+
+// warnLimited logs a warning unless too many warnings with the same key were logged recently
+func warnLimited(key string, msg string, args ...any) {
+	ok, suppressed := warnLimiter.allow(key, time.Now())
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	slog.Warn(msg, args...)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogLimiterAllow(t *testing.T) {
+	l := logLimiter{limits: make(map[string]*logLimit)}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := range logLimitBurst {
+		if ok, suppressed := l.allow("a", start.Add(time.Duration(i)*time.Second)); !ok || suppressed != 0 {
+			t.Fatalf("message %d: got %v, %d, want allowed", i, ok, suppressed)
+		}
+	}
+	for i := range 3 {
+		if ok, _ := l.allow("a", start.Add(time.Minute)); ok {
+			t.Fatalf("message %d over the burst was allowed", i)
+		}
+	}
+	// other keys have their own limits
+	if ok, _ := l.allow("b", start.Add(time.Minute)); !ok {
+		t.Error("message with another key was not allowed")
+	}
+
+	// next window reports the suppressed count once
+	ok, suppressed := l.allow("a", start.Add(logLimitInterval))
+	if !ok || suppressed != 3 {
+		t.Errorf("got %v, %d, want allowed with 3 suppressed", ok, suppressed)
+	}
+	if ok, suppressed := l.allow("a", start.Add(logLimitInterval+time.Second)); !ok || suppressed != 0 {
+		t.Errorf("got %v, %d, want allowed with 0 suppressed", ok, suppressed)
+	}
+}
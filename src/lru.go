@@ -0,0 +1,62 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LruCache is a thread-safe fixed-size cache evicting least recently used entries
+type LruCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used
+	items    map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func NewLruCache[K comparable, V any](capacity int) *LruCache[K, V] {
+	return &LruCache[K, V]{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		items:    make(map[K]*list.Element),
+	}
+}
+
+func (c *LruCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.items[key]; found {
+		c.order.MoveToFront(e)
+		return e.Value.(*lruEntry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *LruCache[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, found := c.items[key]; found {
+		e.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+func (c *LruCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestLruCache(t *testing.T) {
+	c := NewLruCache[string, int](2)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	if v, found := c.Get("a"); !found || v != 1 {
+		t.Fatalf("got %v, %v, want 1", v, found)
+	}
+	// "b" is the least recently used now
+	c.Put("c", 3)
+	if _, found := c.Get("b"); found {
+		t.Error("least recently used entry was not evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("got len %d, want 2", c.Len())
+	}
+
+	// updating an entry makes it the most recent one
+	c.Put("a", 10)
+	c.Put("d", 4)
+	if v, found := c.Get("a"); !found || v != 10 {
+		t.Errorf("got %v, %v, want 10", v, found)
+	}
+	if _, found := c.Get("c"); found {
+		t.Error("least recently used entry was not evicted")
+	}
+}
+
+func TestLruCacheMinCapacity(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		c := NewLruCache[string, int](capacity)
+		c.Put("a", 1)
+		c.Put("b", 2)
+		if c.Len() != 1 {
+			t.Errorf("capacity %d: got len %d, want 1", capacity, c.Len())
+		}
+		if v, found := c.Get("b"); !found || v != 2 {
+			t.Errorf("capacity %d: got %v, %v, want 2", capacity, v, found)
+		}
+	}
+}
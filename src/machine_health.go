@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const maxMachineIncidents = 1000
+
+const reliabilitySampleInterval = time.Hour
+const reliabilityHistoryLength = 7 * 24 // samples
+
+type MachineIncident struct {
+	MachineId         int        `json:"machine_id"`
+	Kind              string     `json:"kind"` // offline, reliability_drop
+	Start             time.Time  `json:"start"`
+	End               *time.Time `json:"end,omitempty"`
+	Duration          float64    `json:"duration,omitempty"` // in seconds, for finished offline incidents
+	ReliabilityBefore float64    `json:"reliability_before,omitempty"`
+	ReliabilityAfter  float64    `json:"reliability_after,omitempty"`
+}
+
+type reliabilitySample struct {
+	Ts    time.Time `json:"ts"`
+	Value float64   `json:"value"`
+}
+
+type trackedMachine struct {
+	Online              bool                `json:"online"`
+	LastSeen            time.Time           `json:"lastSeen"`
+	OpenIncident        int                 `json:"openIncident"` // index in Incidents + 1, 0 if none
+	OfflineSecondsTotal float64             `json:"offlineSecondsTotal"`
+	IncidentsTotal      map[string]int      `json:"incidentsTotal"`
+	ReliabilityBaseline float64             `json:"reliabilityBaseline"`
+	ReliabilityHistory  []reliabilitySample `json:"reliabilityHistory"`
+}
+
+type MachineHealthTracker struct {
+	mu        sync.Mutex
+	Machines  map[int]*trackedMachine `json:"machines"`
+	Incidents []MachineIncident       `json:"incidents"`
+	// time of the previous update by this process, offline time is only accrued between updates,
+	// not over the downtime of the exporter
+	updated time.Time
+	ts      time.Time
+}
+
+type MachineHealthUpdate struct {
+	OfflineSeconds map[int]float64   // offline time accrued since the previous update
+	Incidents      []MachineIncident // incidents started during this update
+}
+
+type MachineIncidentsResponse struct {
+	Url       string            `json:"url"`
+	Timestamp time.Time         `json:"timestamp"`
+	Count     int               `json:"count"`
+	Notes     []string          `json:"notes,omitempty"`
+	Incidents []MachineIncident `json:"incidents"`
+}
+
+func loadMachineHealthTracker() *MachineHealthTracker {
+	tracker := &MachineHealthTracker{
+		Machines: make(map[int]*trackedMachine),
+	}
+
+	j, err := os.ReadFile(machineHealthFile())
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Could not read machine health state", "err", err)
+		}
+		return tracker
+	}
+	if err := json.Unmarshal(j, tracker); err != nil {
+		slog.Error("Could not read machine health state", "err", err)
+		return tracker
+	}
+	if tracker.Machines == nil {
+		tracker.Machines = make(map[int]*trackedMachine)
+	}
+
+	slog.Info("Loaded machine health state", "machines", len(tracker.Machines), "incidents", len(tracker.Incidents))
+	return tracker
+}
+
+func (tracker *MachineHealthTracker) UpdateFrom(machines []VastAiMachine, now time.Time) MachineHealthUpdate {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	result := MachineHealthUpdate{
+		OfflineSeconds: make(map[int]float64),
+	}
+	changed := false
+
+	accrueOffline := func(id int, t *trackedMachine) {
+		if tracker.updated.IsZero() {
+			return
+		}
+		d := now.Sub(tracker.updated).Seconds()
+		t.OfflineSecondsTotal += d
+		result.OfflineSeconds[id] = d
+	}
+
+	openIncident := func(t *trackedMachine, incident MachineIncident) {
+		tracker.Incidents = append(tracker.Incidents, incident)
+		result.Incidents = append(result.Incidents, incident)
+		if t.IncidentsTotal == nil {
+			t.IncidentsTotal = make(map[string]int)
+		}
+		t.IncidentsTotal[incident.Kind]++
+		changed = true
+	}
+
+	for _, machine := range machines {
+		online := machine.Timeout == 0
+
+		t, found := tracker.Machines[machine.Id]
+		if !found {
+			t = &trackedMachine{
+				Online:              true,
+				ReliabilityBaseline: machine.Reliability,
+			}
+			tracker.Machines[machine.Id] = t
+			changed = true
+		}
+
+		// offline incidents
+		if !online && !t.Online {
+			accrueOffline(machine.Id, t)
+		}
+		if !online && t.Online {
+			openIncident(t, MachineIncident{
+				MachineId: machine.Id,
+				Kind:      "offline",
+				Start:     now,
+			})
+			t.OpenIncident = len(tracker.Incidents)
+		}
+		if online && !t.Online {
+			accrueOffline(machine.Id, t)
+			if t.OpenIncident > 0 && t.OpenIncident <= len(tracker.Incidents) {
+				incident := &tracker.Incidents[t.OpenIncident-1]
+				end := now
+				incident.End = &end
+				incident.Duration = end.Sub(incident.Start).Seconds()
+			}
+			t.OpenIncident = 0
+			changed = true
+		}
+		t.Online = online
+
+		// reliability drops
+		if t.ReliabilityBaseline-machine.Reliability >= settings().reliabilityDropThreshold {
+			openIncident(t, MachineIncident{
+				MachineId:         machine.Id,
+				Kind:              "reliability_drop",
+				Start:             now,
+				End:               &now,
+				ReliabilityBefore: t.ReliabilityBaseline,
+				ReliabilityAfter:  machine.Reliability,
+			})
+			t.ReliabilityBaseline = machine.Reliability
+		} else if machine.Reliability > t.ReliabilityBaseline {
+			t.ReliabilityBaseline = machine.Reliability
+			changed = true
+		}
+
+		// reliability history
+		n := len(t.ReliabilityHistory)
+		if n == 0 || now.Sub(t.ReliabilityHistory[n-1].Ts) >= reliabilitySampleInterval {
+			t.ReliabilityHistory = append(t.ReliabilityHistory, reliabilitySample{Ts: now, Value: machine.Reliability})
+			if len(t.ReliabilityHistory) > reliabilityHistoryLength {
+				t.ReliabilityHistory = slices.Clone(t.ReliabilityHistory[len(t.ReliabilityHistory)-reliabilityHistoryLength:])
+			}
+			changed = true
+		}
+
+		t.LastSeen = now
+	}
+
+	// keep the incident log bounded, fixing up references to open incidents
+	if excess := len(tracker.Incidents) - maxMachineIncidents; excess > 0 {
+		tracker.Incidents = slices.Clone(tracker.Incidents[excess:])
+		for _, t := range tracker.Machines {
+			t.OpenIncident = max(t.OpenIncident-excess, 0)
+		}
+	}
+	tracker.ts = now
+	tracker.updated = now
+
+	if changed || len(result.OfflineSeconds) > 0 {
+		tracker.save()
+	}
+
+	return result
+}
+
+// ReliabilityChange returns the change of reliability over the given period, or false if there is not enough history
+func (tracker *MachineHealthTracker) ReliabilityChange(machineId int, current float64, period time.Duration, now time.Time) (float64, bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	t, ok := tracker.Machines[machineId]
+	if !ok {
+		return 0, false
+	}
+	for _, sample := range slices.Backward(t.ReliabilityHistory) {
+		if now.Sub(sample.Ts) >= period {
+			return current - sample.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Totals returns persisted offline seconds and incident counts, used to restore counters after restart
+func (tracker *MachineHealthTracker) Totals() (map[int]float64, map[int]map[string]int) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	offline := make(map[int]float64, len(tracker.Machines))
+	incidents := make(map[int]map[string]int, len(tracker.Machines))
+	for id, t := range tracker.Machines {
+		offline[id] = t.OfflineSecondsTotal
+		incidents[id] = t.IncidentsTotal
+	}
+	return offline, incidents
+}
+
+// Response returns the incident history (newest first) ready to be served with jsonHandler.
+func (tracker *MachineHealthTracker) Response() *CachedResponse {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	incidents := slices.Clone(tracker.Incidents)
+	slices.Reverse(incidents)
+
+	j, err := json.MarshalIndent(MachineIncidentsResponse{
+		Url:       "/my/incidents",
+		Timestamp: tracker.ts.UTC(),
+		Count:     len(incidents),
+		Notes: []string{
+			"Sorted from newest to oldest.",
+			"Offline incidents without end are still ongoing.",
+		},
+		Incidents: incidents,
+	}, "", "    ")
+	if err != nil {
+		slog.Error("Could not serialize response", "endpoint", "/my/incidents", "err", err)
+		return nil
+	}
+
+	return &CachedResponse{ts: tracker.ts, etag: makeEtag(tracker.ts, "/my/incidents"), raw: j}
+}
+
+func (tracker *MachineHealthTracker) save() {
+	j, err := json.Marshal(tracker)
+	if err != nil {
+		slog.Error("Could not store machine health state", "err", err)
+		return
+	}
+	err = writeFileAtomic(machineHealthFile(), j, 0600)
+	if err != nil {
+		slog.Error("Could not store machine health state", "err", err)
+	}
+}
+
+func machineHealthFile() string {
+	return *stateDir + "/.vastai_machine_health"
+}
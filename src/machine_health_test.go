@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMachineHealthOfflineIncidents(t *testing.T) {
+	prevStateDir := *stateDir
+	*stateDir = t.TempDir()
+	currentSettings.Store(&runtimeSettings{reliabilityDropThreshold: 0.05})
+	t.Cleanup(func() {
+		*stateDir = prevStateDir
+		currentSettings.Store(nil)
+	})
+
+	tracker := loadMachineHealthTracker()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	online := VastAiMachine{Id: 1, Reliability: 0.99}
+	offline := VastAiMachine{Id: 1, Reliability: 0.99, Timeout: 10}
+
+	tracker.UpdateFrom([]VastAiMachine{online}, start)
+	update := tracker.UpdateFrom([]VastAiMachine{offline}, start.Add(time.Minute))
+	if len(update.Incidents) != 1 || update.Incidents[0].Kind != "offline" {
+		t.Fatalf("got incidents %+v, want offline", update.Incidents)
+	}
+	update = tracker.UpdateFrom([]VastAiMachine{offline}, start.Add(2*time.Minute))
+	if update.OfflineSeconds[1] != 60 {
+		t.Errorf("got %v offline seconds, want 60", update.OfflineSeconds[1])
+	}
+	update = tracker.UpdateFrom([]VastAiMachine{online}, start.Add(3*time.Minute))
+	if update.OfflineSeconds[1] != 60 {
+		t.Errorf("got %v offline seconds, want 60", update.OfflineSeconds[1])
+	}
+
+	incident := tracker.Incidents[0]
+	if incident.End == nil || incident.Duration != 120 {
+		t.Errorf("got %+v, want finished incident of 120s", incident)
+	}
+	offlineSeconds, incidents := tracker.Totals()
+	if offlineSeconds[1] != 120 || incidents[1]["offline"] != 1 {
+		t.Errorf("got totals %v %v", offlineSeconds, incidents)
+	}
+
+	drop := VastAiMachine{Id: 1, Reliability: 0.9}
+	update = tracker.UpdateFrom([]VastAiMachine{drop}, start.Add(4*time.Minute))
+	if len(update.Incidents) != 1 || update.Incidents[0].Kind != "reliability_drop" || update.Incidents[0].ReliabilityBefore != 0.99 {
+		t.Errorf("got incidents %+v, want reliability drop from 0.99", update.Incidents)
+	}
+}
+
+func TestMachineHealthRestart(t *testing.T) {
+	prevStateDir := *stateDir
+	*stateDir = t.TempDir()
+	currentSettings.Store(&runtimeSettings{reliabilityDropThreshold: 0.05})
+	t.Cleanup(func() {
+		*stateDir = prevStateDir
+		currentSettings.Store(nil)
+	})
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	offline := VastAiMachine{Id: 1, Reliability: 0.99, Timeout: 10}
+
+	tracker := loadMachineHealthTracker()
+	tracker.UpdateFrom([]VastAiMachine{{Id: 1, Reliability: 0.99}}, start)
+	tracker.UpdateFrom([]VastAiMachine{offline}, start.Add(time.Minute))
+	tracker.UpdateFrom([]VastAiMachine{offline}, start.Add(2*time.Minute))
+
+	// the exporter is down for an hour, its downtime is not counted as offline time
+	restored := loadMachineHealthTracker()
+	now := start.Add(62 * time.Minute)
+	update := restored.UpdateFrom([]VastAiMachine{offline}, now)
+	if len(update.OfflineSeconds) != 0 || len(update.Incidents) != 0 {
+		t.Errorf("got %+v on the first update after restart, want nothing", update)
+	}
+	update = restored.UpdateFrom([]VastAiMachine{offline}, now.Add(time.Minute))
+	if update.OfflineSeconds[1] != 60 {
+		t.Errorf("got %v offline seconds, want 60", update.OfflineSeconds[1])
+	}
+	if offlineSeconds, _ := restored.Totals(); offlineSeconds[1] != 120 {
+		t.Errorf("got %v offline seconds in total, want 120", offlineSeconds[1])
+	}
+}
+
+func TestMachineHealthSavesOnlyChanges(t *testing.T) {
+	prevStateDir := *stateDir
+	*stateDir = t.TempDir()
+	currentSettings.Store(&runtimeSettings{reliabilityDropThreshold: 0.05})
+	t.Cleanup(func() {
+		*stateDir = prevStateDir
+		currentSettings.Store(nil)
+	})
+
+	tracker := loadMachineHealthTracker()
+	machines := []VastAiMachine{{Id: 1, Reliability: 0.99}}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.UpdateFrom(machines, start)
+	if _, err := os.Stat(machineHealthFile()); err != nil {
+		t.Fatalf("state was not saved: %v", err)
+	}
+
+	if err := os.Remove(machineHealthFile()); err != nil {
+		t.Fatal(err)
+	}
+	tracker.UpdateFrom(machines, start.Add(15*time.Second))
+	if _, err := os.Stat(machineHealthFile()); err == nil {
+		t.Errorf("state was saved without changes")
+	}
+
+	// a new reliability sample is a change
+	tracker.UpdateFrom(machines, start.Add(reliabilitySampleInterval))
+	if _, err := os.Stat(machineHealthFile()); err != nil {
+		t.Errorf("state was not saved after a change: %v", err)
+	}
+}
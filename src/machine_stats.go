@@ -121,6 +121,26 @@ func (machines VastAiMachineOffers) stats(perDlPerf bool) MachineStats {
 	return result
 }
 
+// bid levels in dollars per GPU/hour
+var bidLadderLevels = []float64{0.05, 0.1, 0.15, 0.2, 0.25, 0.3, 0.4, 0.5, 0.75, 1, 1.5, 2, 3, 5}
+
+// counts available GPUs on machines accepting interruptible rentals with min bid at or below each level (cumulative)
+func (machines VastAiMachineOffers) bidLadder(levels []float64) []int {
+	result := make([]int, len(levels))
+	for _, m := range machines {
+		available := m.NumGpus - m.NumGpusRented
+		if m.MinBidPerGpu <= 0 || available <= 0 {
+			continue
+		}
+		for i, level := range levels {
+			if m.MinBidPerGpu <= level {
+				result[i] += available
+			}
+		}
+	}
+	return result
+}
+
 func (machines VastAiMachineOffers) gpuInfo() *GpuInfo {
 	if len(machines) == 0 {
 		return nil
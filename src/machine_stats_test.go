@@ -0,0 +1,22 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBidLadder(t *testing.T) {
+	machines := VastAiMachineOffers{
+		{MachineId: 1, NumGpus: 8, NumGpusRented: 6, MinBidPerGpu: 0.1},
+		{MachineId: 2, NumGpus: 4, MinBidPerGpu: 0.3},
+		// fully rented machines have nothing to offer
+		{MachineId: 3, NumGpus: 2, NumGpusRented: 2, MinBidPerGpu: 0.1},
+		// interruptible rentals are not accepted
+		{MachineId: 4, NumGpus: 2},
+	}
+	got := machines.bidLadder([]float64{0.05, 0.1, 0.2, 0.5})
+	want := []int{0, 2, 2, 6}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -28,6 +28,7 @@ type CategorizedStats_Category struct {
 
 	Verified      bool
 	Datacenter    bool
+	HostClass     HostClass
 	GpuCountRange CategorizedStats_GpuCountRange
 
 	Stats CategorizedStats_CategoryStats
@@ -37,6 +38,7 @@ type categoryKey struct {
 	gpuName       string
 	verified      bool
 	datacenter    bool
+	hostClass     HostClass
 	gpuCountRange CategorizedStats_GpuCountRange
 }
 
@@ -73,6 +75,9 @@ func compareCategories(a, b CategorizedStats_Category) int {
 	if c := compareBool(a.Datacenter, b.Datacenter); c != 0 {
 		return c
 	}
+	if c := cmp.Compare(a.HostClass, b.HostClass); c != 0 {
+		return c
+	}
 	if c := cmp.Compare(string(a.GpuCountRange), string(b.GpuCountRange)); c != 0 {
 		return c
 	}
@@ -95,19 +100,28 @@ func computeMachineStats(prices []float64) MachineStats {
 }
 
 func (machines VastAiMachineOffers) categorizedStats() []CategorizedStats_Category {
+	return machines.categorizedStatsBy(func(m *VastAiMachineOffer) categoryKey {
+		return categoryKey{
+			gpuName:       m.GpuName,
+			verified:      m.Verified,
+			datacenter:    m.Datacenter,
+			hostClass:     m.HostClass,
+			gpuCountRange: gpuCountRange(m.NumGpus),
+		}
+	})
+}
+
+// categorizedStatsBy groups machines by the fields set in the key, the rest of fields is left zero
+func (machines VastAiMachineOffers) categorizedStatsBy(makeKey func(m *VastAiMachineOffer) categoryKey) []CategorizedStats_Category {
 	buckets := make(map[categoryKey]*categoryPrices)
 
-	for _, m := range machines {
+	for i := range machines {
+		m := &machines[i]
 		if m.GpuName == "" {
 			continue
 		}
 
-		key := categoryKey{
-			gpuName:       m.GpuName,
-			verified:      m.Verified,
-			datacenter:    m.Datacenter,
-			gpuCountRange: gpuCountRange(m.NumGpus),
-		}
+		key := makeKey(m)
 
 		bucket, ok := buckets[key]
 		if !ok {
@@ -133,6 +147,7 @@ func (machines VastAiMachineOffers) categorizedStats() []CategorizedStats_Catego
 			GpuName:       key.gpuName,
 			Verified:      key.verified,
 			Datacenter:    key.datacenter,
+			HostClass:     key.hostClass,
 			GpuCountRange: key.gpuCountRange,
 			Stats: CategorizedStats_CategoryStats{
 				Rented:    computeMachineStats(bucket.rented),
@@ -189,12 +204,14 @@ func (machines VastAiMachineOffers) categorizedStatsByGpu() []CategorizedStatsGr
 func (e CategorizedStats_Category) MarshalJSON() ([]byte, error) {
 	type jsonEntry struct {
 		Datacenter    bool                           `json:"datacenter"`
+		HostClass     HostClass                      `json:"host_class"`
 		GpuCountRange CategorizedStats_GpuCountRange `json:"gpu_count_range"`
 		Verified      bool                           `json:"verified"`
 		Stats         CategorizedStats_CategoryStats `json:"stats"`
 	}
 	return json.Marshal(jsonEntry{
 		Datacenter:    e.Datacenter,
+		HostClass:     e.HostClass,
 		GpuCountRange: e.GpuCountRange,
 		Verified:      e.Verified,
 		Stats:         e.Stats,
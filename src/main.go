@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -17,18 +22,50 @@ import (
 )
 
 var (
+	configFile = kingpin.Flag(
+		"config",
+		"YAML config file with the same settings as command line flags, reloaded on change or SIGHUP.",
+	).PlaceHolder("FILE").String()
+	webConfigFile = kingpin.Flag(
+		"web.config.file",
+		"Prometheus exporter-toolkit web config file with TLS settings and basic auth users.",
+	).PlaceHolder("FILE.yml").String()
+	webAuthPaths = kingpin.Flag(
+		"web.auth-paths",
+		"Paths which require authentication if configured in --web.config.file, entries ending with / match by prefix.",
+	).Default("/metrics,/my/,/geo-cache").String()
+	logLevelName = kingpin.Flag(
+		"log-level",
+		"Minimal level of log messages: debug, info, warn or error.",
+	).Default("info").Enum("debug", "info", "warn", "error")
+	logFormat = kingpin.Flag(
+		"log-format",
+		"Format of log messages: text or json.",
+	).Default("text").Enum("text", "json")
 	listenAddress = kingpin.Flag(
 		"listen",
 		"Address to listen on.",
 	).Default(":8622").String()
+	debugListenAddress = kingpin.Flag(
+		"debug-listen",
+		"Address to serve pprof and /debug/snapshot on, e.g. localhost:6060 (default disabled).",
+	).PlaceHolder("IP:PORT").String()
 	apiKey = kingpin.Flag(
 		"key",
 		"Vast.ai API key",
 	).Default("").String()
 	updateInterval = kingpin.Flag(
 		"update-interval",
-		"How often to query Vast.ai for updates (default 5s with --master-url, 1m otherwise)",
+		"How often to query Vast.ai for offers (default 5s with --master-url, 1m otherwise)",
 	).Default("0").Duration()
+	accountUpdateInterval = kingpin.Flag(
+		"account-update-interval",
+		"How often to query Vast.ai for machines and instances of the account.",
+	).Default("15s").Duration()
+	invoicesUpdateInterval = kingpin.Flag(
+		"invoices-update-interval",
+		"How often to query Vast.ai for invoices of the account.",
+	).Default("10m").Duration()
 	stateDir = kingpin.Flag(
 		"state-dir",
 		"Path to store state files (default $HOME)",
@@ -41,6 +78,54 @@ var (
 		"maxmind-key",
 		"API key for MaxMind GeoIP web services.",
 	).PlaceHolder("USERID:KEY").String()
+	geoIpDbFiles = kingpin.Flag(
+		"geoip-db",
+		"Local GeoIP2/GeoLite2 City (and optionally ASN or ISP) databases.",
+	).PlaceHolder("FILE.mmdb,FILE.mmdb").String()
+	geoStaticFile = kingpin.Flag(
+		"geo-static",
+		"CSV or JSON file with geolocation overrides for IPs or networks (e.g. own datacenters).",
+	).PlaceHolder("FILE").String()
+	geoHttpUrl = kingpin.Flag(
+		"geo-http-url",
+		"Generic HTTP JSON geolocation service, {ip} is replaced with the IP address.",
+	).PlaceHolder("URL").String()
+	geoHttpFields = kingpin.Flag(
+		"geo-http-fields",
+		"Mapping of geolocation fields to JSON paths in --geo-http-url responses.",
+	).Default("country=countryCode,location=city,lat=lat,long=lon,isp=isp,asn=as,organization=org").String()
+	hostMapCacheSize = kingpin.Flag(
+		"host-map-cache-size",
+		"Max number of filtered /host-map-data responses kept in memory.",
+	).Default("64").Int()
+	geoCountriesFile = kingpin.Flag(
+		"geo-countries-file",
+		"GeoJSON file with country polygons for /host-map-countries.geojson, e.g. Natural Earth admin 0 countries.",
+	).PlaceHolder("FILE").String()
+	geoNegativeTtl = kingpin.Flag(
+		"geo-negative-ttl",
+		"How long to remember IPs which could not be geolocated.",
+	).Default("24h").Duration()
+	geoQps = kingpin.Flag(
+		"geo-qps",
+		"Max rate of remote geolocation queries per second (0 = unlimited).",
+	).Default("5").Float64()
+	geoDailyBudget = kingpin.Flag(
+		"geo-daily-budget",
+		"Max number of remote geolocation queries per day, UTC (0 = unlimited).",
+	).Default("0").Int()
+	geoWorkers = kingpin.Flag(
+		"geo-workers",
+		"Number of background workers for remote geolocation queries.",
+	).Default("4").Int()
+	geoProviders = kingpin.Flag(
+		"geo-providers",
+		"Order in which geolocation providers are queried. Only configured providers are used.",
+	).Default("static,geoip-db,maxmind,http").String()
+	reliabilityDropThreshold = kingpin.Flag(
+		"reliability-drop-threshold",
+		"Record an incident when machine reliability drops by at least this much.",
+	).Default("0.01").Float64()
 	noGeoLocation = kingpin.Flag(
 		"no-geolocation",
 		"Exclude IP ranges from geolocation",
@@ -49,6 +134,10 @@ var (
 		"user-agent",
 		"User-Agent header to use for Vast.ai API requests.",
 	).Default("vastai-exporter/1.0 (+https://github.com/500farm/prometheus-vastai)").String()
+	otlpEndpoint = kingpin.Flag(
+		"otlp-endpoint",
+		"Export traces of update cycles to this OTLP/HTTP endpoint, e.g. http://localhost:4318.",
+	).PlaceHolder("URL").String()
 	downloadTestDataFlag = kingpin.Flag(
 		"download-test-data",
 		"Download raw API data to state-dir/test-data/ and exit.",
@@ -75,20 +164,30 @@ func metricsHandler(w http.ResponseWriter, r *http.Request, collectors ...promet
 	h.ServeHTTP(w, r)
 }
 
+// how long to wait for in-flight requests on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	kingpin.Version(version.Print("vastai_exporter"))
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
+	commandLineFlags = flagsSetOnCommandLine(os.Args[1:])
 
-	if *updateInterval == 0 {
-		if *masterUrl != "" {
-			*updateInterval = 5 * time.Second
-		} else {
-			*updateInterval = 1 * time.Minute
+	if *configFile != "" {
+		if err := loadConfig(*configFile, true); err != nil {
+			fatal("Could not load config", "err", err)
 		}
 	}
 
-	log.SetFlags(0)
+	if err := setupLogging(); err != nil {
+		fatal("Could not set up logging", "err", err)
+	}
+
+	initialSettings, err := readSettings()
+	if err != nil {
+		fatal("Invalid settings", "err", err)
+	}
+	currentSettings.Store(initialSettings)
 
 	if *stateDir == "" {
 		*stateDir = os.Getenv("HOME")
@@ -100,7 +199,7 @@ func main() {
 	// "download test data" mode: fetch from API, save to files, exit.
 	if *downloadTestDataFlag {
 		if *apiKey == "" {
-			log.Fatalln("API key is required for --download-test-data")
+			fatal("API key is required for --download-test-data")
 		}
 		downloadTestData()
 		return
@@ -115,51 +214,111 @@ func main() {
 	}
 
 	if *apiKey == "" {
-		log.Fatalln("API key is required")
+		fatal("API key is required")
 	}
 
-	log.Println("INFO: Starting vast.ai exporter")
+	slog.Info("Starting vast.ai exporter")
 
-	// load or init geolocation cache (will be nil if MaxMind key is not supploid)
-	var err error
-	geoCache, err = loadGeoCache()
+	// cancelled on SIGTERM/SIGINT, stops the update loop, API calls and geolocation workers
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	shutdownTracing, err := setupTracing(ctx)
 	if err != nil {
-		log.Fatalln(err)
+		fatal("Could not set up tracing", "err", err)
 	}
 
-	metrics = newExporterMetrics()
-
-	log.Println("INFO: Reading initial Vast.ai info (may take a minute)")
-
-	// read info from vast.ai: offers
-	info := getVastAiInfo(*masterUrl)
-	err = offerCache.InitialUpdateFrom(info)
+	// load or init geolocation cache (will be nil if no geolocation provider is configured)
+	geoCache, err = loadGeoCache(ctx)
 	if err != nil {
-		// initial update must succeed, otherwise exit
-		log.Fatalln(err)
+		fatal("Could not load geolocation cache", "err", err)
 	}
 
-	snap := offerCache.Snapshot()
+	if *geoCountriesFile != "" {
+		countryGeometries, err = loadCountryGeometries(*geoCountriesFile)
+		if err != nil {
+			fatal("Could not load country geometries", "path", *geoCountriesFile, "err", err)
+		}
+	}
+
+	metrics = newExporterMetrics()
 
-	// read info from vast.ai: global stats
 	vastAiGlobalCollector := newVastAiGlobalCollector()
-	vastAiGlobalCollector.UpdateFrom(snap)
 
-	// read info from vast.ai: account stats (if api key is specified)
+	// account stats (if api key is specified)
 	useAccount := *apiKey != ""
 	vastAiAccountCollector := newVastAiAccountCollector()
-	if useAccount {
-		err = vastAiAccountCollector.InitialUpdateFrom(info, snap)
-		if err != nil {
-			// initial update must succeed, otherwise exit
-			log.Fatalln(err)
+	if !useAccount {
+		slog.Info("No Vast.ai API key provided, only serving global stats")
+	}
+
+	// market, machines/instances and invoices are fetched by independent schedulers, so a slow or failing
+	// call of one doesn't delay the others; failed updates are retried on the next cycle of the same scheduler,
+	// /readyz reports not ready until all of them succeeded once
+	offersLoaded := false
+	updateMarket := func(ctx context.Context) {
+		info := getMarketInfo(ctx, *masterUrl)
+		if ctx.Err() != nil {
+			// shutting down, data may be incomplete
+			return
+		}
+		if offersLoaded {
+			offerCache.UpdateFrom(ctx, info)
+		} else if err := offerCache.InitialUpdateFrom(ctx, info); err != nil {
+			slog.Error("Initial update failed, will retry", "err", err)
+			return
+		} else {
+			offersLoaded = true
 		}
-	} else {
-		log.Println("INFO: No Vast.ai API key provided, only serving global stats")
+		vastAiGlobalCollector.UpdateFrom(offerCache.Snapshot())
+
+		if useAccount {
+			// price stats and the bid ladder of own GPU models follow the market, not the account schedule
+			vastAiAccountCollector.UpdateMarket(offerCache.Snapshot())
+		} else {
+			// not neeeded anymore, with the account they are used by machine metrics until the next update
+			offerCache.ClearMachines()
+		}
+	}
+	updateAccount := func(ctx context.Context) {
+		info := getAccountInfo(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		vastAiAccountCollector.UpdateFrom(info, offerCache.Snapshot())
+	}
+	updateInvoices := func(ctx context.Context) {
+		info := getInvoicesInfo(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		vastAiAccountCollector.UpdateFrom(info, nil)
+	}
+
+	schedulers := []*Scheduler{newScheduler("market", func() time.Duration { return settings().updateInterval }, updateMarket)}
+	if useAccount {
+		schedulers = append(schedulers,
+			newScheduler("account", func() time.Duration { return settings().accountUpdateInterval }, updateAccount),
+			newScheduler("invoices", func() time.Duration { return settings().invoicesUpdateInterval }, updateInvoices),
+		)
 	}
 
 	mux := http.NewServeMux()
 
+	// the admin API is only served with authentication configured in web config
+	var webServer *WebServer
+	adminEnabled := false
+	if *webConfigFile != "" {
+		webServer, err = newWebServer(*webConfigFile, *webAuthPaths, mux)
+		if err != nil {
+			fatal("Could not load web config", "path", *webConfigFile, "err", err)
+		}
+		adminEnabled = webServer.AuthConfigured()
+		if !adminEnabled {
+			slog.Warn("Admin API is disabled, web config has no basic auth users or client certificates", "path", *webConfigFile)
+		}
+	}
+
 	mux.HandleFunc("/offers", func(w http.ResponseWriter, r *http.Request) {
 		jsonHandler(w, r, offerCache.Snapshot().Offers())
 	})
@@ -175,9 +334,61 @@ func main() {
 	mux.HandleFunc("/gpu-stats/v2", func(w http.ResponseWriter, r *http.Request) {
 		jsonHandler(w, r, offerCache.Snapshot().GpuStatsV2())
 	})
+	mux.HandleFunc("/hosts/stats", func(w http.ResponseWriter, r *http.Request) {
+		jsonHandler(w, r, offerCache.Snapshot().HostsStats())
+	})
+	mux.HandleFunc("/data-quality", func(w http.ResponseWriter, r *http.Request) {
+		jsonHandler(w, r, offerCache.Snapshot().DataQuality())
+	})
+	mux.HandleFunc("/isp-stats", func(w http.ResponseWriter, r *http.Request) {
+		jsonHandler(w, r, offerCache.Snapshot().IspStats())
+	})
 	mux.HandleFunc("/host-map-data", func(w http.ResponseWriter, r *http.Request) {
-		filter := r.URL.Query().Get("filter")
-		jsonHandler(w, r, offerCache.Snapshot().HostMapData(filter))
+		resp, err := offerCache.Snapshot().FilteredHostMapData(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonHandler(w, r, resp)
+	})
+	mux.HandleFunc("/host-map-data/grid", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := offerCache.Snapshot().HostMapGrid(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonHandler(w, r, resp)
+	})
+	mux.HandleFunc("/host-map.geojson", func(w http.ResponseWriter, r *http.Request) {
+		jsonHandler(w, r, offerCache.Snapshot().HostMapGeoJson())
+	})
+	mux.HandleFunc("/host-map-countries.geojson", func(w http.ResponseWriter, r *http.Request) {
+		jsonHandler(w, r, offerCache.Snapshot().HostMapCountriesGeoJson())
+	})
+	mux.HandleFunc("/my/events", func(w http.ResponseWriter, r *http.Request) {
+		if !useAccount {
+			http.NotFound(w, r)
+			return
+		}
+		jsonHandler(w, r, vastAiAccountCollector.instanceEvents.Response())
+	})
+	mux.HandleFunc("/my/incidents", func(w http.ResponseWriter, r *http.Request) {
+		if !useAccount {
+			http.NotFound(w, r)
+			return
+		}
+		jsonHandler(w, r, vastAiAccountCollector.machineHealth.Response())
+	})
+	mux.HandleFunc("/geo-cache", geoCacheHandler)
+	if adminEnabled {
+		admin := &AdminHandler{schedulers: schedulers}
+		mux.HandleFunc(adminPathPrefix+"status", admin.status)
+		mux.HandleFunc(adminPathPrefix+"refresh", admin.refresh)
+		mux.HandleFunc(adminPathPrefix+"geo-cache", geoCacheInvalidateHandler)
+	}
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		readyzHandler(w, r, useAccount)
 	})
 
 	mux.HandleFunc("/metrics/global", func(w http.ResponseWriter, r *http.Request) {
@@ -241,7 +452,35 @@ func main() {
 			`<p><a href="hosts">List of hosts</a></p>`,
 			`<p><a href="gpu-stats">Per-model stats on GPUs</a></p>`,
 			`<p><a href="gpu-stats/v2">Per-model stats on GPUs (categorized)</a></p>`,
+			`<p><a href="hosts/stats">Host-level market stats</a></p>`,
+			`<p><a href="data-quality">Inconsistencies in the offer list</a></p>`,
+			`<p><a href="isp-stats">GPU supply by ISP and organization</a></p>`,
 			`<p><a href="host-map-data">Data source for map of hosts</a></p>`,
+			`<p><a href="host-map-data/grid">Density grid for map of hosts</a></p>`,
+			`<p><a href="host-map.geojson">Map of hosts (GeoJSON)</a></p>`,
+			`<p><a href="host-map-countries.geojson">Hosts by country (GeoJSON)</a></p>`,
+		)
+		if useAccount {
+			lines = append(lines,
+				`<hr>`,
+				`<h2>Account JSON endpoints</h2>`,
+				`<p><a href="my/events">Instance lifecycle events</a></p>`,
+				`<p><a href="my/incidents">Machine incidents</a></p>`,
+			)
+		}
+		if geoCache != nil || adminEnabled {
+			lines = append(lines,
+				`<hr>`,
+				`<h2>Admin endpoints</h2>`,
+			)
+		}
+		if geoCache != nil {
+			lines = append(lines, `<p><a href="geo-cache">Geolocation cache</a></p>`)
+		}
+		if adminEnabled {
+			lines = append(lines, `<p><a href="admin/status">Update state</a> (refresh with POST /admin/refresh)</p>`)
+		}
+		lines = append(lines,
 			`</body>`,
 			`</html>`,
 		)
@@ -250,28 +489,71 @@ func main() {
 
 	// "test parsing mode": fetch all endpoints to files and exit.
 	if *testParsingFlag {
+		updateMarket(ctx)
+		updateAccount(ctx)
+		updateInvoices(ctx)
+		if !offersLoaded {
+			fatal("Could not load test data")
+		}
 		testFetchAllEndpoints(mux)
 		return
 	}
 
-	go func() {
-		for {
-			time.Sleep(*updateInterval)
-
-			info := getVastAiInfo(*masterUrl)
-			offerCache.UpdateFrom(info)
-			snap := offerCache.Snapshot()
+	if *configFile != "" {
+		go watchConfig(*configFile)
+	}
 
-			vastAiGlobalCollector.UpdateFrom(snap)
-			if useAccount {
-				vastAiAccountCollector.UpdateFrom(info, snap)
-			}
+	var running sync.WaitGroup
+	slog.Info("Reading initial Vast.ai info (may take a minute)")
+	for _, s := range schedulers {
+		running.Go(func() { s.Run(ctx) })
+	}
 
-			// not neeeded anymore
-			offerCache.ClearMachines()
+	server := &http.Server{Addr: *listenAddress, Handler: mux}
+	listen := server.ListenAndServe
+	if webServer != nil {
+		go webServer.watch(ctx)
+		listen = func() error { return webServer.ListenAndServe(server) }
+	}
+	go func() {
+		slog.Info("Listening", "address", *listenAddress)
+		if err := listen(); !errors.Is(err, http.ErrServerClosed) {
+			fatal("Could not start server", "err", err)
 		}
 	}()
 
-	log.Println("INFO: Listening on", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, mux))
+	var debugServer *http.Server
+	if *debugListenAddress != "" {
+		debugServer = &http.Server{Addr: *debugListenAddress, Handler: debugHandler()}
+		go func() {
+			slog.Info("Listening for debug requests", "address", *debugListenAddress)
+			if err := debugServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				fatal("Could not start debug server", "err", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop() // second signal kills the process
+	slog.Info("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown failed", "err", err)
+	}
+	if debugServer != nil {
+		// long-running profiles are not waited for
+		_ = debugServer.Close()
+	}
+
+	// state files of the update cycles are written by the time they return
+	running.Wait()
+	if geoCache != nil {
+		geoCache.save()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Could not flush traces", "err", err)
+	}
+	slog.Info("Stopped")
 }
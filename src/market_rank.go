@@ -0,0 +1,50 @@
+package main
+
+type MarketRank struct {
+	Price           int // 1 = cheapest
+	DlperfPerDollar int // 1 = most DLPerf per dollar
+	Score           int // 1 = highest search score
+	Comparable      int // number of comparable machines including this one
+}
+
+func (m *VastAiMachineOffer) dlperfPerDollar() float64 {
+	if m.PricePerGpu <= 0 {
+		return 0
+	}
+	return m.DlperfPerGpuChunk / (float64(m.PricePerGpu) / 100)
+}
+
+func (m *VastAiMachineOffer) isComparableTo(other *VastAiMachineOffer) bool {
+	return m.GpuName == other.GpuName &&
+		m.Verified == other.Verified &&
+		m.NumGpus == other.NumGpus
+}
+
+// marketRank finds where the machine ranks among comparable machines (same GPU model, verified status and
+// GPU count); rank is 1 + number of machines which are strictly better by the criterion
+func (machines VastAiMachineOffers) marketRank(m *VastAiMachineOffer) MarketRank {
+	result := MarketRank{Price: 1, DlperfPerDollar: 1, Score: 1}
+	dlperfPerDollar := m.dlperfPerDollar()
+
+	for i := range machines {
+		other := &machines[i]
+		if !m.isComparableTo(other) {
+			continue
+		}
+		result.Comparable++
+		if other.MachineId == m.MachineId {
+			continue
+		}
+		if other.PricePerGpu < m.PricePerGpu {
+			result.Price++
+		}
+		if other.dlperfPerDollar() > dlperfPerDollar {
+			result.DlperfPerDollar++
+		}
+		if other.Score > m.Score {
+			result.Score++
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestMarketRank(t *testing.T) {
+	machines := VastAiMachineOffers{
+		{MachineId: 1, GpuName: "RTX 4090", NumGpus: 1, Verified: true, PricePerGpu: 40, DlperfPerGpuChunk: 80, Score: 100},
+		{MachineId: 2, GpuName: "RTX 4090", NumGpus: 1, Verified: true, PricePerGpu: 30, DlperfPerGpuChunk: 75, Score: 200},
+		{MachineId: 3, GpuName: "RTX 4090", NumGpus: 1, Verified: true, PricePerGpu: 40, DlperfPerGpuChunk: 60, Score: 50},
+		// not comparable: other GPU count, verification or model
+		{MachineId: 4, GpuName: "RTX 4090", NumGpus: 2, Verified: true, PricePerGpu: 10, DlperfPerGpuChunk: 80, Score: 999},
+		{MachineId: 5, GpuName: "RTX 4090", NumGpus: 1, Verified: false, PricePerGpu: 10, DlperfPerGpuChunk: 80, Score: 999},
+		{MachineId: 6, GpuName: "RTX 3090", NumGpus: 1, Verified: true, PricePerGpu: 10, DlperfPerGpuChunk: 80, Score: 999},
+	}
+	tests := []struct {
+		machine int
+		want    MarketRank
+	}{
+		// DLPerf per dollar: 1 → 200, 2 → 250, 3 → 150
+		{0, MarketRank{Price: 2, DlperfPerDollar: 2, Score: 2, Comparable: 3}},
+		{1, MarketRank{Price: 1, DlperfPerDollar: 1, Score: 1, Comparable: 3}},
+		// ties don't lower the rank
+		{2, MarketRank{Price: 2, DlperfPerDollar: 3, Score: 3, Comparable: 3}},
+		{3, MarketRank{Price: 1, DlperfPerDollar: 1, Score: 1, Comparable: 1}},
+	}
+	for _, tt := range tests {
+		if got := machines.marketRank(&machines[tt.machine]); got != tt.want {
+			t.Errorf("machine %d: got %+v, want %+v", machines[tt.machine].MachineId, got, tt.want)
+		}
+	}
+}
+
+func TestDlperfPerDollarOfFreeMachine(t *testing.T) {
+	m := VastAiMachineOffer{DlperfPerGpuChunk: 80}
+	if got := m.dlperfPerDollar(); got != 0 {
+		t.Errorf("got %v, want 0", got)
+	}
+}
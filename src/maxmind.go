@@ -1,54 +1,31 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"io/fs"
-	"log"
-	"math/rand/v2"
-	"net"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/netip"
 	"strings"
+	"sync/atomic"
 	"time"
-)
-
-type GeoLocation struct {
-	Country      string  `json:"country,omitempty"`
-	Location     string  `json:"location,omitempty"`
-	Lat          float64 `json:"lat,omitempty"`
-	Long         float64 `json:"long,omitempty"`
-	Accuracy     float64 `json:"accuracy,omitempty"` // in kilometers
-	ISP          string  `json:"isp,omitempty"`
-	Organization string  `json:"organization,omitempty"`
-	Domain       string  `json:"domain,omitempty"`
-}
-
-type GeoCacheEntry struct {
-	Expires  time.Time    `json:"Expires"`
-	Location *GeoLocation `json:"Location"`
-}
-
-type GeoCacheEntries map[string]GeoCacheEntry
 
-type GeoCache struct {
-	Entries     GeoCacheEntries `json:"Entries"`
-	maxMindUser string
-	maxMindPass string
-	failed      bool
-	skipNets    []net.IPNet
-}
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
 
+// GeoIP2 City web service response, GeoIP2/GeoLite2 City databases are decoded into mmdbCityRecord
 type MaxMindResponse struct {
 	Country struct {
 		IsoCode string `json:"iso_code"`
 	} `json:"country"`
 	Traits struct {
-		Isp          string `json:"isp"`
-		Organization string `json:"organization"`
-		Domain       string `json:"domain"`
+		Isp                    string `json:"isp"`
+		AutonomousSystemNumber uint32 `json:"autonomous_system_number"`
+		Organization           string `json:"organization"`
+		Domain                 string `json:"domain"`
 	} `json:"traits"`
 	City struct {
 		Names struct {
@@ -60,127 +37,53 @@ type MaxMindResponse struct {
 		Lat      float64 `json:"latitude"`
 		Long     float64 `json:"longitude"`
 	} `json:"location"`
-	SubDivisions []struct {
-		IsoCode string `json:"iso_code"`
-		Names   struct {
-			En string `json:"en"`
-		} `json:"names"`
-	} `json:"subdivisions"`
+	SubDivisions []MaxMindSubdivision `json:"subdivisions"`
 }
 
-const GeoLocationTTL = 7 * 24 * time.Hour
-const GeoLocationTTLVariance = 3 * time.Hour
-
-var geoCache *GeoCache
-
-func newGeoCache() *GeoCache {
-	return &GeoCache{
-		Entries: make(GeoCacheEntries),
-	}
+type MaxMindSubdivision struct {
+	IsoCode string `json:"iso_code" maxminddb:"iso_code"`
+	Names   struct {
+		En string `json:"en" maxminddb:"en"`
+	} `json:"names" maxminddb:"names"`
 }
 
-func loadGeoCache() (*GeoCache, error) {
-	if *maxMindKey == "" {
-		return nil, nil
-	}
+const maxMindUrl = "https://geoip.maxmind.com/geoip/v2.1/city/"
 
-	cache := newGeoCache()
+// MaxMind GeoIP2 City web service
+type MaxMindProvider struct {
+	url    string
+	user   string
+	pass   string
+	failed atomic.Bool
+}
 
-	t := strings.Split(*maxMindKey, ":")
+func newMaxMindProvider(key string) (*MaxMindProvider, error) {
+	t := strings.Split(key, ":")
 	if len(t) != 2 {
-		return nil, fmt.Errorf(`invalid MaxMind auth "%s": please specify user id and license key separated with ":"`, *maxMindKey)
-	}
-	cache.maxMindUser = t[0]
-	cache.maxMindPass = t[1]
-
-	j, err := os.ReadFile(geoCacheFile())
-	if err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			return nil, err
-		}
-	} else {
-		err := json.Unmarshal(j, &cache)
-		if err != nil {
-			return nil, err
-		}
+		return nil, fmt.Errorf(`invalid MaxMind auth "%s": please specify user id and license key separated with ":"`, key)
 	}
-
-	cache.removeExpired()
-	log.Printf("INFO: Loaded geolocation cache: %d items", len(cache.Entries))
-
-	if *noGeoLocation != "" {
-		for netStr := range strings.SplitSeq(*noGeoLocation, ",") {
-			netStr = strings.TrimSpace(netStr)
-			if len(netStr) > 0 {
-				_, net, err := net.ParseCIDR(netStr)
-				if err != nil {
-					log.Println("ERROR:", err)
-				} else {
-					cache.skipNets = append(cache.skipNets, *net)
-				}
-			}
-		}
-		log.Printf("INFO: Will skip geolocation for: %v", cache.skipNets)
-	}
-
-	return cache, nil
+	return &MaxMindProvider{url: maxMindUrl, user: t[0], pass: t[1]}, nil
 }
 
-func (cache *GeoCache) ipLocation(ip string, machineId int) *GeoLocation {
-	parsedIp := net.ParseIP(ip)
-	if parsedIp == nil {
-		log.Printf("WARN: Invalid IP address: %s (machine=%d)", ip, machineId)
-		return nil
-	}
-	if !parsedIp.IsGlobalUnicast() || parsedIp.IsPrivate() || isCgnatIp(parsedIp) {
-		log.Printf("WARN: IP address from an invalid range: %s (machine=%d)", ip, machineId)
-		return nil
-	}
-	for _, net := range cache.skipNets {
-		if net.Contains(parsedIp) {
-			log.Printf("INFO: Skipped geolocation for IP: %s (machine=%d)", ip, machineId)
-			return nil
-		}
-	}
-
-	entry, found := cache.Entries[ip]
-	if found && !entry.expired() {
-		return entry.Location
-	}
+func (p *MaxMindProvider) Name() string    { return "maxmind" }
+func (p *MaxMindProvider) Cacheable() bool { return true }
 
-	if cache.failed {
-		return nil
+func (p *MaxMindProvider) Lookup(ctx context.Context, ip netip.Addr) (_ *GeoLocation, err error) {
+	if p.failed.Load() {
+		return nil, errGeoProviderDisabled
 	}
 
-	location, err := cache.queryMaxMind(ip)
-	if err == nil {
-		cache.Entries[ip] = GeoCacheEntry{
-			Expires:  makeExpireTime(),
-			Location: location,
-		}
-	} else {
-		log.Println("ERROR:", err)
-	}
-	return location
-}
+	// lookups are done by geolocation workers, so these spans are not part of update cycles
+	ctx, span := tracer.Start(ctx, "maxmind.lookup", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { endSpan(span, err) }()
 
-func (cache *GeoCache) save() {
-	cache.removeExpired()
-	j, _ := json.MarshalIndent(cache, "", "    ") //nolint:errchkjson // GeoCache contains only safe JSON types
-	err := os.WriteFile(geoCacheFile(), j, 0600)
-	if err != nil {
-		log.Println("ERROR:", err)
-	}
-}
-
-func (cache *GeoCache) queryMaxMind(ip string) (*GeoLocation, error) {
 	client := &http.Client{Timeout: 5 * time.Second}
-	url := fmt.Sprintf("https://geoip.maxmind.com/geoip/v2.1/city/%s?pretty", ip)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	url := p.url + ip.String() + "?pretty"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(cache.maxMindUser, cache.maxMindPass)
+	req.SetBasicAuth(p.user, p.pass)
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
@@ -191,17 +94,21 @@ func (cache *GeoCache) queryMaxMind(ip string) (*GeoLocation, error) {
 		return nil, err
 	}
 	code := resp.StatusCode
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", code),
+		attribute.Int("http.response.body.size", len(body)),
+	)
 	if code == 404 {
 		// IP not found in database, it's not an error
-		log.Println("WARN: IP not found by MaxMind:", ip)
+		slog.Debug("IP not found by MaxMind", "ip", ip.String())
 		return nil, nil
 	}
 	if code != 200 {
-		log.Println("ERROR:", string(body))
+		logErrorBody(body)
 		if code == 401 || code == 402 {
 			// 401 Unauthorized or 402 Payment Required
-			cache.failed = true
-			log.Println("ERROR: Disabling MaxMind until restart because of following:")
+			p.failed.Store(true)
+			slog.Error("Disabling MaxMind until restart", "status", resp.Status)
 		}
 		return nil, fmt.Errorf("%s returned: %s", url, resp.Status)
 	}
@@ -210,7 +117,10 @@ func (cache *GeoCache) queryMaxMind(ip string) (*GeoLocation, error) {
 	if err != nil {
 		return nil, err
 	}
+	return j.geoLocation(), nil
+}
 
+func (j *MaxMindResponse) geoLocation() *GeoLocation {
 	r := GeoLocation{
 		Country:  j.Country.IsoCode,
 		Location: j.City.Names.En,
@@ -218,6 +128,7 @@ func (cache *GeoCache) queryMaxMind(ip string) (*GeoLocation, error) {
 		Long:     j.Location.Long,
 		Accuracy: j.Location.Accuracy,
 		ISP:      j.Traits.Isp,
+		ASN:      j.Traits.AutonomousSystemNumber,
 		Domain:   j.Traits.Domain,
 	}
 	for i := len(j.SubDivisions) - 1; i >= 0; i-- {
@@ -233,46 +144,5 @@ func (cache *GeoCache) queryMaxMind(ip string) (*GeoLocation, error) {
 	if j.Traits.Organization != j.Traits.Isp {
 		r.Organization = j.Traits.Organization
 	}
-	return &r, nil
-}
-
-func (cache *GeoCache) removeExpired() {
-	cache.Entries = cache.Entries.removeExpired()
-}
-
-func (entry *GeoCacheEntry) expired() bool {
-	return entry.Expires.Before(time.Now())
-}
-
-func (entries GeoCacheEntries) removeExpired() GeoCacheEntries {
-	newEntries := make(GeoCacheEntries)
-	for ip, entry := range entries {
-		if !entry.expired() {
-			newEntries[ip] = entry
-		}
-	}
-	return newEntries
-}
-
-func makeExpireTime() time.Time {
-	// randomize so all entries do not expire at the same time
-	extra := time.Duration(rand.Int64N(int64(GeoLocationTTLVariance)))
-	return time.Now().Add(GeoLocationTTL).Add(extra)
-}
-
-func geoCacheFile() string {
-	return *stateDir + "/.vastai_geo_cache"
-}
-
-var _, cgnNet, _ = net.ParseCIDR("100.64.0.0/10")
-
-func isCgnatIp(ip net.IP) bool {
-	if ip == nil {
-		return false
-	}
-	ip = ip.To4()
-	if ip == nil {
-		return false
-	}
-	return cgnNet.Contains(ip)
+	return &r
 }
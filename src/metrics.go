@@ -13,6 +13,8 @@ type ExporterMetrics struct {
 	machineCount prometheus.Gauge
 	hostCount    prometheus.Gauge
 
+	lastSuccessfulUpdateTimestamp *prometheus.GaugeVec
+
 	apiRequestDurationSeconds *prometheus.GaugeVec
 	apiResponseSizeBytes      *prometheus.GaugeVec
 	apiBytesRead              *prometheus.CounterVec
@@ -28,6 +30,16 @@ type ExporterMetrics struct {
 	processSecondsTotal    *prometheus.CounterVec
 
 	marshalerBufferCapBytes *prometheus.GaugeVec
+
+	hostMapCacheRequestsTotal *prometheus.CounterVec
+
+	offerAnomalies *prometheus.GaugeVec
+
+	geoQueueDepth            prometheus.Gauge
+	geoLookupDurationSeconds *prometheus.HistogramVec
+	geoLookupsTotal          *prometheus.CounterVec
+	geoDroppedTotal          *prometheus.CounterVec
+	geoBudgetUsed            prometheus.Gauge
 }
 
 func newExporterMetrics() *ExporterMetrics {
@@ -35,6 +47,7 @@ func newExporterMetrics() *ExporterMetrics {
 	subsystemAPI := "api"
 	subsystemServer := "server"
 	subsystemProcess := "process"
+	subsystemGeo := "geo"
 
 	return &ExporterMetrics{
 		offerCount: prometheus.NewGauge(prometheus.GaugeOpts{
@@ -52,6 +65,11 @@ func newExporterMetrics() *ExporterMetrics {
 			Name:      "host_count",
 			Help:      "Number of unique hosts currently tracked.",
 		}),
+		lastSuccessfulUpdateTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_successful_update_timestamp_seconds",
+			Help:      "Unix time of the last successful fetch by data source (offers, machines, instances, payouts).",
+		}, []string{"source"}),
 
 		apiRequestDurationSeconds: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
@@ -122,6 +140,51 @@ func newExporterMetrics() *ExporterMetrics {
 			Name:      "marshaler_buffer_cap_bytes",
 			Help:      "Total capacity of preallocated marshaler buffers in bytes.",
 		}, []string{"endpoint"}),
+
+		hostMapCacheRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystemServer,
+			Name:      "host_map_cache_requests_total",
+			Help:      "Total number of filtered host map requests by result of cache lookup (hit, miss).",
+		}, []string{"result"}),
+
+		offerAnomalies: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "offer_anomalies",
+			Help:      "Number of inconsistencies found in the current snapshot of the offer list by kind.",
+		}, []string{"kind"}),
+
+		geoQueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystemGeo,
+			Name:      "queue_depth",
+			Help:      "Number of IPs waiting for remote geolocation.",
+		}),
+		geoLookupDurationSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystemGeo,
+			Name:      "lookup_duration_seconds",
+			Help:      "Duration of remote geolocation queries in seconds.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}, []string{"provider"}),
+		geoLookupsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystemGeo,
+			Name:      "lookups_total",
+			Help:      "Total number of remote geolocation queries by result (found, not_found, error).",
+		}, []string{"provider", "result"}),
+		geoDroppedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystemGeo,
+			Name:      "dropped_total",
+			Help:      "Total number of geolocation requests dropped by reason (queue_full, budget).",
+		}, []string{"reason"}),
+		geoBudgetUsed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystemGeo,
+			Name:      "budget_used",
+			Help:      "Number of remote geolocation queries spent today (UTC).",
+		}),
 	}
 }
 
@@ -129,6 +192,7 @@ func (m *ExporterMetrics) Describe(ch chan<- *prometheus.Desc) {
 	m.offerCount.Describe(ch)
 	m.machineCount.Describe(ch)
 	m.hostCount.Describe(ch)
+	m.lastSuccessfulUpdateTimestamp.Describe(ch)
 
 	m.apiRequestDurationSeconds.Describe(ch)
 	m.apiResponseSizeBytes.Describe(ch)
@@ -145,12 +209,23 @@ func (m *ExporterMetrics) Describe(ch chan<- *prometheus.Desc) {
 	m.processSecondsTotal.Describe(ch)
 
 	m.marshalerBufferCapBytes.Describe(ch)
+
+	m.hostMapCacheRequestsTotal.Describe(ch)
+
+	m.offerAnomalies.Describe(ch)
+
+	m.geoQueueDepth.Describe(ch)
+	m.geoLookupDurationSeconds.Describe(ch)
+	m.geoLookupsTotal.Describe(ch)
+	m.geoDroppedTotal.Describe(ch)
+	m.geoBudgetUsed.Describe(ch)
 }
 
 func (m *ExporterMetrics) Collect(ch chan<- prometheus.Metric) {
 	m.offerCount.Collect(ch)
 	m.machineCount.Collect(ch)
 	m.hostCount.Collect(ch)
+	m.lastSuccessfulUpdateTimestamp.Collect(ch)
 
 	m.apiRequestDurationSeconds.Collect(ch)
 	m.apiResponseSizeBytes.Collect(ch)
@@ -169,6 +244,19 @@ func (m *ExporterMetrics) Collect(ch chan<- prometheus.Metric) {
 	m.marshalerBufferCapBytes.WithLabelValues("offers").Set(float64(offersMarshaler.BufCap()))
 	m.marshalerBufferCapBytes.WithLabelValues("machines").Set(float64(machinesMarshaler.BufCap()))
 	m.marshalerBufferCapBytes.Collect(ch)
+
+	m.hostMapCacheRequestsTotal.Collect(ch)
+
+	m.offerAnomalies.Collect(ch)
+
+	if geoCache != nil {
+		m.geoQueueDepth.Set(float64(geoCache.queueDepth()))
+	}
+	m.geoQueueDepth.Collect(ch)
+	m.geoLookupDurationSeconds.Collect(ch)
+	m.geoLookupsTotal.Collect(ch)
+	m.geoDroppedTotal.Collect(ch)
+	m.geoBudgetUsed.Collect(ch)
 }
 
 func (m *ExporterMetrics) ObserveAPIDuration(endpoint string, seconds float64) {
@@ -195,6 +283,33 @@ func (m *ExporterMetrics) ObserveAPIError(endpoint string, status string) {
 	m.apiErrorsTotal.WithLabelValues(endpoint, status).Inc()
 }
 
+func (m *ExporterMetrics) ObserveHostMapCache(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.hostMapCacheRequestsTotal.WithLabelValues(result).Inc()
+}
+
+func (m *ExporterMetrics) UpdateOfferAnomalies(counts map[string]int) {
+	for kind, count := range counts {
+		m.offerAnomalies.WithLabelValues(kind).Set(float64(count))
+	}
+}
+
+func (m *ExporterMetrics) ObserveGeoLookup(provider string, result string, d time.Duration) {
+	m.geoLookupDurationSeconds.WithLabelValues(provider).Observe(d.Seconds())
+	m.geoLookupsTotal.WithLabelValues(provider, result).Inc()
+}
+
+func (m *ExporterMetrics) ObserveGeoDropped(reason string) {
+	m.geoDroppedTotal.WithLabelValues(reason).Inc()
+}
+
+func (m *ExporterMetrics) ObserveUpdateSuccess(source string, ts time.Time) {
+	m.lastSuccessfulUpdateTimestamp.WithLabelValues(source).Set(float64(ts.Unix()))
+}
+
 func (m *ExporterMetrics) UpdateCounts(offers, machines int) {
 	m.offerCount.Set(float64(offers))
 	m.machineCount.Set(float64(machines))
@@ -205,10 +320,3 @@ func (m *ExporterMetrics) ObserveProcessing(stage string, d time.Duration) {
 	m.processDurationSeconds.WithLabelValues(stage).Set(seconds)
 	m.processSecondsTotal.WithLabelValues(stage).Add(seconds)
 }
-
-func (m *ExporterMetrics) TimeProcessing(stage string) func() {
-	start := time.Now()
-	return func() {
-		m.ObserveProcessing(stage, time.Since(start))
-	}
-}
\ No newline at end of file
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"runtime"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// timeStage measures a processing stage, the result goes to metrics and to /admin/status
 func timeStage(stage string) func() {
-	if metrics != nil {
-		return metrics.TimeProcessing(stage)
+	start := time.Now()
+	return func() {
+		d := time.Since(start)
+		stageDurations.set(stage, d)
+		if metrics != nil {
+			metrics.ObserveProcessing(stage, d)
+		}
 	}
-	return func() {}
 }
 
 type OfferCache struct {
@@ -20,29 +28,52 @@ type OfferCache struct {
 	offerCount int
 	machines   VastAiMachineOffers
 	responses  SerializedResponses
+	hostMap    *HostMapQueries
+	hostsStats *HostsStats
+	ispStats   *IspStats
 	ts         time.Time
 }
 
 var offerCache OfferCache
 
-func (cache *OfferCache) UpdateFrom(apiRes VastAiApiResults) {
+func (cache *OfferCache) UpdateFrom(ctx context.Context, apiRes VastAiApiResults) {
 	if apiRes.offers != nil {
+		anomalies := &OfferAnomalies{}
+
 		done := timeStage("decode")
-		offers := apiRes.offers.decode()
+		_, span := tracer.Start(ctx, "decode")
+		offers := apiRes.offers.decode(anomalies)
+		span.SetAttributes(attribute.Int("raw_offers", len(*apiRes.offers)), attribute.Int("offers", len(offers)))
+		span.End()
 		done()
 
 		done = timeStage("collect_machines")
-		machines := offers.collectMachineOffers()
+		_, span = tracer.Start(ctx, "collect_machines")
+		machines := offers.collectMachineOffers(anomalies)
+		span.SetAttributes(attribute.Int("machines", len(machines)), attribute.Int("anomalies", len(anomalies.items)))
+		span.End()
 		done()
 
-		log.Println("INFO:", len(offers), "offers,", len(machines), "machines")
+		slog.Info("Updated offers", "offers", len(offers), "machines", len(machines))
 
+		_, span = tracer.Start(ctx, "serialize")
 		responses := NewSerializedResponses(offers, machines, apiRes.ts)
+		hostMap := newHostMapQueries(machines, apiRes.ts)
+		hostsStats := machines.hostsStats(apiRes.ts)
+		responses["/hosts/stats"] = hostsStats.serialize(apiRes.ts)
+		ispStats := machines.ispStats()
+		responses["/isp-stats"] = serializeIspStats(ispStats, apiRes.ts)
+		responses["/data-quality"] = dataQuality.update(anomalies, apiRes.ts)
+		span.SetAttributes(attribute.Int("responses", len(responses)), attribute.Int("bytes", responses.size()))
+		span.End()
 
 		cache.mu.Lock()
 		cache.offerCount = len(offers)
 		cache.machines = machines
 		cache.responses = responses
+		cache.hostMap = hostMap
+		cache.hostsStats = hostsStats
+		cache.ispStats = &ispStats
 		cache.ts = apiRes.ts
 		cache.mu.Unlock()
 
@@ -58,11 +89,11 @@ func (cache *OfferCache) UpdateFrom(apiRes VastAiApiResults) {
 	}
 }
 
-func (cache *OfferCache) InitialUpdateFrom(apiRes VastAiApiResults) error {
+func (cache *OfferCache) InitialUpdateFrom(ctx context.Context, apiRes VastAiApiResults) error {
 	if apiRes.offers == nil {
 		return errors.New("could not read offer data from Vast.ai")
 	}
-	cache.UpdateFrom(apiRes)
+	cache.UpdateFrom(ctx, apiRes)
 	return nil
 }
 
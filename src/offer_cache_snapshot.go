@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/url"
 	"time"
 )
 
@@ -8,6 +9,9 @@ type OfferCacheSnapshot struct {
 	offerCount int
 	machines   VastAiMachineOffers
 	responses  SerializedResponses
+	hostMap    *HostMapQueries
+	hostsStats *HostsStats
+	ispStats   *IspStats
 	ts         time.Time
 }
 
@@ -19,6 +23,9 @@ func (cache *OfferCache) Snapshot() *OfferCacheSnapshot {
 		offerCount: cache.offerCount,
 		machines:   cache.machines,
 		responses:  cache.responses,
+		hostMap:    cache.hostMap,
+		hostsStats: cache.hostsStats,
+		ispStats:   cache.ispStats,
 		ts:         cache.ts,
 	}
 }
@@ -35,9 +42,55 @@ func (snap *OfferCacheSnapshot) Machines() *CachedResponse   { return snap.getCa
 func (snap *OfferCacheSnapshot) Hosts() *CachedResponse      { return snap.getCachedResponse("/hosts") }
 func (snap *OfferCacheSnapshot) GpuStats() *CachedResponse   { return snap.getCachedResponse("/gpu-stats") }
 func (snap *OfferCacheSnapshot) GpuStatsV2() *CachedResponse { return snap.getCachedResponse("/gpu-stats/v2") }
+func (snap *OfferCacheSnapshot) HostsStats() *CachedResponse {
+	return snap.getCachedResponse("/hosts/stats")
+}
+func (snap *OfferCacheSnapshot) DataQuality() *CachedResponse {
+	return snap.getCachedResponse("/data-quality")
+}
+func (snap *OfferCacheSnapshot) IspStats() *CachedResponse {
+	return snap.getCachedResponse("/isp-stats")
+}
+func (snap *OfferCacheSnapshot) HostMapGeoJson() *CachedResponse {
+	return snap.getCachedResponse("/host-map.geojson")
+}
+func (snap *OfferCacheSnapshot) HostMapCountriesGeoJson() *CachedResponse {
+	return snap.getCachedResponse("/host-map-countries.geojson")
+}
 func (snap *OfferCacheSnapshot) HostMapData(filter string) *CachedResponse {
 	if filter == "" {
 		return snap.getCachedResponse("/host-map-data")
 	}
 	return snap.getCachedResponse("/host-map-data?filter=" + filter)
 }
+
+// FilteredHostMapData returns pre-built responses for presets, other filters are computed on demand
+func (snap *OfferCacheSnapshot) FilteredHostMapData(query url.Values) (*CachedResponse, error) {
+	if len(query) == 0 {
+		return snap.HostMapData(""), nil
+	}
+	if len(query) == 1 && query.Has("filter") {
+		if resp := snap.HostMapData(query.Get("filter")); resp != nil {
+			return resp, nil
+		}
+	}
+	f, err := parseHostMapFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	if snap.hostMap == nil {
+		return nil, nil
+	}
+	return snap.hostMap.Response(f), nil
+}
+
+func (snap *OfferCacheSnapshot) HostMapGrid(query url.Values) (*CachedResponse, error) {
+	precision, f, err := parseGridQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if snap.hostMap == nil {
+		return nil, nil
+	}
+	return snap.hostMap.GridResponse(precision, f), nil
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"cmp"
 	"fmt"
-	"log"
 	"slices"
 
 	"github.com/hashicorp/go-set/v2"
@@ -19,7 +18,8 @@ type VastAiMachineOffer struct {
 	NumGpus           int
 	NumGpusRented     int
 	MinChunk          int
-	PricePerGpu       int // in cents
+	PricePerGpu       int     // in cents
+	MinBidPerGpu      float64 // in dollars
 	Verified          bool
 	Datacenter        bool
 	StaticIp          bool
@@ -32,9 +32,12 @@ type VastAiMachineOffer struct {
 	DlperfPerGpuChunk float64
 	DlperfPerGpuWhole float64
 	TflopsPerGpu      float64
+	Score             float64
 	GpuIds            []int
 	Chunks            []Chunk2
 	Location          *GeoLocation
+	HostClass         HostClass
+	ClassConfidence   float64 // confidence of host class, 0 to 1
 }
 
 type Chunk2 struct {
@@ -88,6 +91,9 @@ func (m *VastAiMachineOffer) asRaw() VastAiRawOffer {
 		result["location"] = m.Location
 	}
 
+	result["host_class"] = m.HostClass
+	result["host_class_confidence"] = m.ClassConfidence
+
 	result.fixFloats()
 
 	return result
@@ -109,7 +115,7 @@ func (chunk Chunk) gpuIdsSorted() []int {
 	return gpuIds
 }
 
-func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
+func (offers VastAiOffers) collectMachineOffers(anomalies *OfferAnomalies) VastAiMachineOffers {
 	result := make(VastAiMachineOffers, 0, len(offers)/4)
 
 	offers.groupByMachineId(func(machineId int, group VastAiOffers) {
@@ -146,7 +152,8 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 				if wholeMachine == nil {
 					wholeMachine = &chunk
 				} else {
-					log.Println("WARN:", fmt.Sprintf("Offer list inconsistency: machine %d listed multiple times", machineId))
+					warnLimited("offer_inconsistency", "Offer list inconsistency: machine listed multiple times", "machine_id", machineId)
+					anomalies.add(anomalyDuplicateMachine, machineId, []int{wholeMachine.offerId, chunk.offerId}, nil)
 				}
 			}
 			if chunk.rentable {
@@ -155,7 +162,12 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 		}
 
 		if wholeMachine == nil {
-			log.Println("WARN:", fmt.Sprintf("Offer list inconsistency: machine %d has no chunk with frac=1.0, skipping", machineId))
+			warnLimited("offer_inconsistency", "Offer list inconsistency: machine has no chunk with frac=1.0, skipping", "machine_id", machineId)
+			offerIds := make([]int, 0, len(chunks))
+			for _, chunk := range chunks {
+				offerIds = append(offerIds, chunk.offerId)
+			}
+			anomalies.add(anomalyNoWholeMachine, machineId, offerIds, nil)
 			return
 		}
 
@@ -190,8 +202,9 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 				offerIds = append(offerIds, chunk.offerId)
 				chunkSizes = append(chunkSizes, chunk.size)
 			}
-			log.Println("WARN:", fmt.Sprintf("Offer list inconsistency: machine %d has weird chunk set %v, offer_ids %v",
-				machineId, chunkSizes, offerIds))
+			warnLimited("offer_inconsistency", "Offer list inconsistency: machine has weird chunk set",
+				"machine_id", machineId, "chunk_sizes", fmt.Sprint(chunkSizes), "offer_ids", fmt.Sprint(offerIds))
+			anomalies.add(anomalyWeirdChunks, machineId, offerIds, chunkSizes)
 		}
 
 		// - build chunks2 for the decoded machine
@@ -236,8 +249,10 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 
 		// - build the decoded whole machine
 		pricePerGpu := 0
+		minBidPerGpu := 0.0
 		if totalGpus > 0 {
 			pricePerGpu = int(wholeMachine.offer.DphBase / float64(totalGpus) * 100)
+			minBidPerGpu = wholeMachine.offer.MinBid / float64(totalGpus)
 		}
 
 		if location == nil {
@@ -253,6 +268,7 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 			NumGpusRented:     usedGpus,
 			MinChunk:          minChunkSize,
 			PricePerGpu:       pricePerGpu,
+			MinBidPerGpu:      minBidPerGpu,
 			Verified:          wholeMachine.offer.Verified,
 			Datacenter:        wholeMachine.offer.Datacenter,
 			StaticIp:          wholeMachine.offer.StaticIp,
@@ -263,6 +279,7 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 			InetUp:            maxUp,
 			InetDown:          maxDown,
 			DlperfPerGpuChunk: dlperfPerGpuChunk,
+			Score:             wholeMachine.offer.Score,
 			GpuIds:            wholeMachine.gpuIdsSorted(),
 			Chunks:            chunks2,
 			Location:          location,
@@ -275,5 +292,7 @@ func (offers VastAiOffers) collectMachineOffers() VastAiMachineOffers {
 		result = append(result, wm)
 	})
 
+	result.classifyHosts()
+
 	return result
 }
@@ -33,6 +33,8 @@ func parallelDo(total, workers int, fn func(w, start, end int)) {
 
 // Marshaler with preallocated buffers to avoid a lot of allocations when producing big JSON documents
 type Marshaler struct {
+	// held while marshaling, buffers may be replaced when they grow
+	mu sync.Mutex
 	workerBufs []*bytes.Buffer
 	rawBuf *FlipBuffer
 	gzipBuf *FlipBuffer
@@ -62,6 +64,9 @@ func NewMarshaler() *Marshaler {
 
 // returned slices are owned by the marshaler's FlipBuffers and are valid until the next-but-one Marshal call
 func (s *Marshaler) Marshal(v any) (raw []byte, gzipped []byte, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	rawBuf := s.rawBuf.Flip()
 
 	// produce raw value
@@ -85,6 +90,12 @@ func (s *Marshaler) Marshal(v any) (raw []byte, gzipped []byte, err error) {
 
 // returns total capacity of all preallocated buffers in bytes
 func (s *Marshaler) BufCap() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bufCap()
+}
+
+func (s *Marshaler) bufCap() int {
 	n := s.rawBuf.Cap() + s.gzipBuf.Cap()
 	for _, buf := range s.workerBufs {
 		n += buf.Cap()
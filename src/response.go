@@ -29,7 +29,11 @@ func jsonHandler(w http.ResponseWriter, r *http.Request, cached *CachedResponse)
 	isHead := r.Method == http.MethodHead
 	endpoint := r.URL.Path
 
-	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(endpoint, ".geojson") {
+		w.Header().Set("Content-Type", "application/geo+json")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
 	w.Header().Set("Last-Modified", cached.ts.UTC().Format(http.TimeFormat))
 	w.Header().Set("ETag", cached.etag)
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// lower bound of the interval between cycles, so a bad setting can't make a tight loop
+const minSchedulerInterval = time.Second
+
+// Scheduler calls update every interval, or right away when a refresh is requested by the admin API
+type Scheduler struct {
+	name     string
+	interval func() time.Duration
+	update   func(ctx context.Context)
+	// holds at most one pending refresh request
+	trigger chan struct{}
+
+	mu           sync.Mutex
+	running      bool
+	cycles       int
+	lastStart    time.Time
+	lastDuration time.Duration
+	nextRun      time.Time
+}
+
+type SchedulerStatus struct {
+	Name                string    `json:"name"`
+	IntervalSeconds     float64   `json:"interval_seconds"`
+	Running             bool      `json:"running"`
+	Cycles              int       `json:"cycles"`
+	LastStart           time.Time `json:"last_start,omitzero"`
+	LastDurationSeconds float64   `json:"last_duration_seconds"`
+	NextRun             time.Time `json:"next_run,omitzero"`
+}
+
+func newScheduler(name string, interval func() time.Duration, update func(ctx context.Context)) *Scheduler {
+	return &Scheduler{
+		name:     name,
+		interval: interval,
+		update:   update,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Run calls update until the context is cancelled, the interval is read on every cycle
+// so it can be changed by config reload; each cycle is traced as a span named after the scheduler
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		s.runCycle(ctx)
+
+		interval := s.currentInterval()
+		s.mu.Lock()
+		s.nextRun = time.Now().Add(interval)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-s.trigger:
+			timer.Stop()
+			slog.Debug("Refresh requested", "scheduler", s.name)
+		}
+	}
+}
+
+func (s *Scheduler) currentInterval() time.Duration {
+	return max(s.interval(), minSchedulerInterval)
+}
+
+func (s *Scheduler) runCycle(ctx context.Context) {
+	start := time.Now()
+	s.mu.Lock()
+	s.running = true
+	s.lastStart = start
+	s.nextRun = time.Time{}
+	s.mu.Unlock()
+
+	cycleCtx, span := tracer.Start(ctx, "update "+s.name)
+	s.update(cycleCtx)
+	span.End()
+
+	s.mu.Lock()
+	s.running = false
+	s.cycles++
+	s.lastDuration = time.Since(start)
+	s.mu.Unlock()
+}
+
+// Refresh requests a cycle now, returns false if one is already running or requested
+func (s *Scheduler) Refresh() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return false
+	}
+	select {
+	case s.trigger <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Scheduler) Status() SchedulerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SchedulerStatus{
+		Name:                s.name,
+		IntervalSeconds:     s.currentInterval().Seconds(),
+		Running:             s.running,
+		Cycles:              s.cycles,
+		LastStart:           s.lastStart.UTC(),
+		LastDurationSeconds: s.lastDuration.Seconds(),
+		NextRun:             s.nextRun.UTC(),
+	}
+}
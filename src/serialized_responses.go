@@ -6,7 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"slices"
 	"time"
 
@@ -23,7 +23,7 @@ func NewSerializedResponses(
 	machines VastAiMachineOffers,
 	ts time.Time,
 ) SerializedResponses {
-	responses := make(SerializedResponses, 7)
+	responses := make(SerializedResponses, 14)
 
 	responses["/offers"] = serializeOffers(&offers, ts)
 	responses["/machines"] = serializeMachines(&machines, ts)
@@ -42,9 +42,22 @@ func NewSerializedResponses(
 	responses["/host-map-data?filter=top-10"] = hostMapData.top(10).serialize("/host-map-data?filter=top-10", ts, true)
 	responses["/host-map-data?filter=top-100"] = hostMapData.top(100).serialize("/host-map-data?filter=top-100", ts, true)
 
+	responses["/host-map.geojson"], responses["/host-map-countries.geojson"] = serializeHostMapGeoJson(machines, ts)
+
 	return responses
 }
 
+// size returns total size of uncompressed responses in bytes
+func (responses SerializedResponses) size() int {
+	total := 0
+	for _, resp := range responses {
+		if resp != nil {
+			total += len(resp.raw)
+		}
+	}
+	return total
+}
+
 func makeEtag(ts time.Time, endpoint string) string {
 	hash := sha256.Sum256([]byte(ts.Format(time.RFC3339Nano) + "|" + endpoint))
 	return fmt.Sprintf(`"%x"`, hash[:8])
@@ -73,8 +86,7 @@ func buildCachedResponse(ts time.Time, endpoint string, jsonBytes []byte) *Cache
 		gzipped: gzip(jsonBytes),
 	}
 
-	log.Printf("INFO: Pre-serialized %s: %d bytes raw, %d bytes gzipped",
-		endpoint, len(jsonBytes), len(resp.gzipped))
+	slog.Debug("Pre-serialized response", "endpoint", endpoint, "bytes", len(jsonBytes), "gzipped_bytes", len(resp.gzipped))
 
 	return resp
 }
@@ -107,12 +119,11 @@ func serializeOffers(offers *VastAiOffers, ts time.Time) *CachedResponse {
 	})
 
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not serialize response", "endpoint", "/offers", "err", err)
 		return buildCachedResponse(ts, "/offers", nil)
 	}
 
-	log.Printf("INFO: Pre-serialized /offers: %d bytes raw, %d bytes gzipped",
-		len(raw), len(gzipped))
+	slog.Debug("Pre-serialized response", "endpoint", "/offers", "bytes", len(raw), "gzipped_bytes", len(gzipped))
 
 	return &CachedResponse{ts: ts, etag: makeEtag(ts, "/offers"), raw: raw, gzipped: gzipped}
 }
@@ -138,12 +149,11 @@ func serializeMachines(machines *VastAiMachineOffers, ts time.Time) *CachedRespo
 	})
 
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not serialize response", "endpoint", "/machines", "err", err)
 		return buildCachedResponse(ts, "/machines", nil)
 	}
 
-	log.Printf("INFO: Pre-serialized /machines: %d bytes raw, %d bytes gzipped",
-		len(raw), len(gzipped))
+	slog.Debug("Pre-serialized response", "endpoint", "/machines", "bytes", len(raw), "gzipped_bytes", len(gzipped))
 
 	return &CachedResponse{ts: ts, etag: makeEtag(ts, "/machines"), raw: raw, gzipped: gzipped}
 }
@@ -164,7 +174,7 @@ func serializeHosts(machines VastAiMachineOffers, ts time.Time) *CachedResponse
 		Hosts:     &hosts,
 	}, "", "    ")
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not serialize response", "endpoint", "/hosts", "err", err)
 		return buildCachedResponse(ts, "/hosts", nil)
 	}
 
@@ -191,7 +201,7 @@ func serializeGpuStats(machines VastAiMachineOffers, ts time.Time) *CachedRespon
 
 	j, err := json.MarshalIndent(result, "", "    ")
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not serialize response", "endpoint", "/gpu-stats", "err", err)
 		return buildCachedResponse(ts, "/gpu-stats", nil)
 	}
 	return buildCachedResponse(ts, "/gpu-stats", j)
@@ -251,7 +261,7 @@ func serializeGpuStatsV2(machines VastAiMachineOffers, ts time.Time) *CachedResp
 
 	j, err := json.MarshalIndent(result, "", "    ")
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not serialize response", "endpoint", "/gpu-stats/v2", "err", err)
 		return buildCachedResponse(ts, "/gpu-stats/v2", nil)
 	}
 	return buildCachedResponse(ts, "/gpu-stats/v2", j)
@@ -295,10 +305,11 @@ func (items HostMapItems) serialize(url string, ts time.Time, withZeroPoint bool
 		Items: serializedItems,
 		Notes: []string{
 			"Use ?filter= to select a subset: all, dc, non-dc, top-10, top-100",
+			"Or combine filters: gpu=, country=, min_tflops=, top=N, verified=, datacenter=, isp=",
 		},
 	}, "", "    ")
 	if err != nil {
-		log.Println("ERROR:", err)
+		slog.Error("Could not serialize response", "endpoint", url, "err", err)
 		return buildCachedResponse(ts, url, nil)
 	}
 
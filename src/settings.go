@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// runtimeSettings are the reloadable settings used outside of the config goroutine,
+// flags are only written by loadConfig, so other goroutines read them through settings()
+type runtimeSettings struct {
+	updateInterval           time.Duration
+	accountUpdateInterval    time.Duration
+	invoicesUpdateInterval   time.Duration
+	userAgent                string
+	geoNegativeTtl           time.Duration
+	geoDailyBudget           int
+	hostMapCacheSize         int
+	reliabilityDropThreshold float64
+}
+
+var currentSettings atomic.Pointer[runtimeSettings]
+
+// settings returns the last validated settings, zero values before they are published on start
+func settings() *runtimeSettings {
+	if s := currentSettings.Load(); s != nil {
+		return s
+	}
+	return &runtimeSettings{}
+}
+
+// readSettings collects the settings from the flags, resolves defaults and validates them
+func readSettings() (*runtimeSettings, error) {
+	s := &runtimeSettings{
+		updateInterval:           *updateInterval,
+		accountUpdateInterval:    *accountUpdateInterval,
+		invoicesUpdateInterval:   *invoicesUpdateInterval,
+		userAgent:                *userAgent,
+		geoNegativeTtl:           *geoNegativeTtl,
+		geoDailyBudget:           *geoDailyBudget,
+		hostMapCacheSize:         *hostMapCacheSize,
+		reliabilityDropThreshold: *reliabilityDropThreshold,
+	}
+	if s.updateInterval == 0 {
+		if *masterUrl != "" {
+			s.updateInterval = 5 * time.Second
+		} else {
+			s.updateInterval = 1 * time.Minute
+		}
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *runtimeSettings) validate() error {
+	switch {
+	case s.updateInterval < 0:
+		return fmt.Errorf("update-interval must not be negative")
+	case s.accountUpdateInterval <= 0:
+		return fmt.Errorf("account-update-interval must be positive")
+	case s.invoicesUpdateInterval <= 0:
+		return fmt.Errorf("invoices-update-interval must be positive")
+	case s.geoNegativeTtl < 0:
+		return fmt.Errorf("geo-negative-ttl must not be negative")
+	case s.geoDailyBudget < 0:
+		return fmt.Errorf("geo-daily-budget must not be negative")
+	case s.hostMapCacheSize < 0:
+		return fmt.Errorf("host-map-cache-size must not be negative")
+	case s.reliabilityDropThreshold < 0:
+		return fmt.Errorf("reliability-drop-threshold must not be negative")
+	}
+	return nil
+}
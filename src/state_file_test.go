@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".state")
+
+	if err := writeFileAtomic(path, []byte("first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "second" {
+		t.Errorf("got %q, %v, want second", data, err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("got mode %v, %v, want 0600", stat.Mode().Perm(), err)
+	}
+
+	// temp files are renamed over the state file, nothing is left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("got %v, %v, want only the state file", entries, err)
+	}
+}
+
+func TestWriteFileAtomicError(t *testing.T) {
+	dir := t.TempDir()
+
+	// the final rename fails when the path is a non-empty directory, so the temp file is written first
+	path := filepath.Join(dir, ".state")
+	existing := filepath.Join(path, "existing")
+	if err := os.MkdirAll(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("new"), 0600); err == nil {
+		t.Error("got no error renaming over a directory")
+	}
+	if data, err := os.ReadFile(existing); err != nil || string(data) != "old" {
+		t.Errorf("got %q, %v, want the existing state kept", data, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("got %v, %v, want no temp files left", entries, err)
+	}
+
+	// a missing directory fails before anything is written
+	if err := writeFileAtomic(filepath.Join(dir, "missing", ".state"), []byte("new"), 0600); err == nil {
+		t.Error("got no error for a missing directory")
+	}
+}
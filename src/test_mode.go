@@ -1,8 +1,9 @@
 package main
 
 import (
+	"context"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -41,18 +42,18 @@ func readTestData(endpoint string) ([]byte, bool) {
 	path := filepath.Join(testDataSource, filename)
 	body, err := os.ReadFile(path)
 	if err != nil {
-		log.Printf("ERROR: %v", err)
+		slog.Error("Could not read test data", "err", err)
 		return nil, false
 	}
 
-	log.Printf("INFO: Read %s (%d bytes)", path, len(body))
+	slog.Info("Read test data", "path", path, "bytes", len(body))
 	return body, true
 }
 
 func downloadTestData() {
 	dir := filepath.Join(*stateDir, "test-data")
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Fatalln("ERROR:", err)
+		fatal("Could not create test data directory", "err", err)
 	}
 
 	type fetch struct {
@@ -71,30 +72,30 @@ func downloadTestData() {
 		{"instances", testFileInstances, "instances", nil, defaultTimeout},
 		{"invoices", testFileInvoices, "users/current/invoices", nil, defaultTimeout},
 	} {
-		log.Printf("INFO: Downloading %s...", f.name)
+		slog.Info("Downloading test data", "name", f.name)
 
-		body, err := vastApiCallRaw(f.endpoint, f.args, f.timeout)
+		body, err := vastApiCallRaw(context.Background(), f.endpoint, f.args, f.timeout)
 		if err != nil {
-			log.Fatalf("ERROR: Failed to fetch %s: %v", f.name, err)
+			fatal("Failed to fetch test data", "name", f.name, "err", err)
 		}
 
 		path := filepath.Join(dir, f.file)
 		if err := os.WriteFile(path, body, 0644); err != nil {
-			log.Fatalf("ERROR: Failed to write %s: %v", path, err)
+			fatal("Failed to write test data", "path", path, "err", err)
 		}
 
-		log.Printf("INFO: Saved %s (%d bytes)", path, len(body))
+		slog.Info("Saved test data", "path", path, "bytes", len(body))
 
 		time.Sleep(queryInterval)
 	}
 
-	log.Println("INFO: All test data downloaded to", dir)
+	slog.Info("All test data downloaded", "dir", dir)
 }
 
 func testFetchAllEndpoints(mux http.Handler) {
 	outDir := filepath.Join(*stateDir, "test-output")
 	if err := os.MkdirAll(outDir, 0755); err != nil {
-		log.Fatalln("ERROR:", err)
+		fatal("Could not create test output directory", "err", err)
 	}
 
 	ts := httptest.NewServer(mux)
@@ -106,35 +107,43 @@ func testFetchAllEndpoints(mux http.Handler) {
 		{"/hosts", "hosts.json"},
 		{"/gpu-stats", "gpu-stats.json"},
 		{"/gpu-stats/v2", "gpu-stats-v2.json"},
+		{"/hosts/stats", "hosts-stats.json"},
+		{"/data-quality", "data-quality.json"},
+		{"/isp-stats", "isp-stats.json"},
 		{"/host-map-data", "host-map-data.json"},
 		{"/host-map-data?filter=dc", "host-map-data-dc.json"},
 		{"/host-map-data?filter=non-dc", "host-map-data-non-dc.json"},
 		{"/host-map-data?filter=top-10", "host-map-data-top-10.json"},
 		{"/host-map-data?filter=top-100", "host-map-data-top-100.json"},
+		{"/host-map-data/grid", "host-map-data-grid.json"},
+		{"/host-map.geojson", "host-map.geojson"},
+		{"/host-map-countries.geojson", "host-map-countries.geojson"},
+		{"/my/events", "my-events.json"},
+		{"/my/incidents", "my-incidents.json"},
 		{"/metrics", "metrics.txt"},
 		{"/metrics/global", "metrics-global.txt"},
 	} {
 		resp, err := ts.Client().Get(ts.URL + ep.path)
 		if err != nil {
-			log.Printf("ERROR: GET %s: %v", ep.path, err)
+			slog.Error("Test request failed", "path", ep.path, "err", err)
 			continue
 		}
 		body, _ := io.ReadAll(resp.Body)
 		_ = resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			log.Printf("ERROR: GET %s: %s", ep.path, resp.Status)
+			slog.Error("Test request failed", "path", ep.path, "status", resp.Status)
 			continue
 		}
 
 		path := filepath.Join(outDir, ep.file)
 		if err := os.WriteFile(path, body, 0644); err != nil {
-			log.Printf("ERROR: %v", err)
+			slog.Error("Could not write test output", "err", err)
 			continue
 		}
 
-		log.Printf("INFO: Wrote %s (%d bytes)", path, len(body))
+		slog.Info("Wrote test output", "path", path, "bytes", len(body))
 	}
 
-	log.Println("INFO: All test output written to", outDir)
+	slog.Info("All test output written", "dir", outDir)
 }
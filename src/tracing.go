@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/prometheus/common/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records spans of update cycles, it is a no-op unless --otlp-endpoint is set
+var tracer = otel.Tracer("prometheus-vastai")
+
+// setupTracing starts exporting spans over OTLP/HTTP, the returned function flushes and stops the export
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if *otlpEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(*otlpEndpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "vastai-exporter"),
+			attribute.String("service.version", version.Version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		warnLimited("otlp", "Could not export traces", "err", err)
+	}))
+	slog.Info("Exporting traces", "endpoint", *otlpEndpoint)
+	return provider.Shutdown, nil
+}
+
+// endSpan marks the span as failed if err is not nil and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestUpdateCycleSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/offers" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"url": "/offers", "timestamp": "2026-01-01T00:00:00Z", "offers": []}`))
+	}))
+	defer master.Close()
+
+	s := newScheduler("market", func() time.Duration { return time.Minute }, func(ctx context.Context) {
+		offerCache.UpdateFrom(ctx, getMarketInfo(ctx, master.URL))
+	})
+	s.runCycle(context.Background())
+
+	spans := exporter.GetSpans()
+	var root tracetest.SpanStub
+	children := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		if span.Name == "update market" {
+			root = span
+		} else {
+			children[span.Name] = span
+		}
+	}
+	if !root.SpanContext.IsValid() || root.Parent.IsValid() {
+		t.Fatalf("no root span of the cycle in %v", spanNames(spans))
+	}
+	for _, name := range []string{"GET master/offers", "decode", "collect_machines", "serialize"} {
+		child, found := children[name]
+		if !found {
+			t.Errorf("no %q span in %v", name, spanNames(spans))
+			continue
+		}
+		if child.Parent.SpanID() != root.SpanContext.SpanID() || child.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("%q is not a child of the cycle span", name)
+		}
+	}
+	if len(spans) != 5 {
+		t.Errorf("got spans %v, want 5", spanNames(spans))
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	slices.Sort(names)
+	return names
+}
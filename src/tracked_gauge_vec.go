@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TrackedGaugeVec remembers label sets set since the last Sweep, so series of label values which disappeared
+// (providers, GPU models) can be deleted without Reset, which would leave the vector empty for concurrent scrapes
+type TrackedGaugeVec struct {
+	*prometheus.GaugeVec
+	current map[string]prometheus.Labels
+	prev    map[string]prometheus.Labels
+}
+
+func newTrackedGaugeVec(opts prometheus.GaugeOpts, labelNames []string) *TrackedGaugeVec {
+	return &TrackedGaugeVec{
+		GaugeVec: prometheus.NewGaugeVec(opts, labelNames),
+		current:  make(map[string]prometheus.Labels),
+	}
+}
+
+func (v *TrackedGaugeVec) Set(labels prometheus.Labels, value float64) {
+	v.With(labels).Set(value)
+	v.current[labelsKey(labels)] = labels
+}
+
+// Sweep deletes series which were not set since the previous Sweep
+func (v *TrackedGaugeVec) Sweep() {
+	for key, labels := range v.prev {
+		if _, found := v.current[key]; !found {
+			v.Delete(labels)
+		}
+	}
+	v.prev = v.current
+	v.current = make(map[string]prometheus.Labels, len(v.prev))
+}
+
+func labelsKey(labels prometheus.Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte(0)
+		sb.WriteString(labels[name])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
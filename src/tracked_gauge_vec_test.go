@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTrackedGaugeVecSweep(t *testing.T) {
+	v := newTrackedGaugeVec(prometheus.GaugeOpts{Name: "test"}, []string{"name"})
+
+	v.Set(prometheus.Labels{"name": "a"}, 1)
+	v.Set(prometheus.Labels{"name": "b"}, 2)
+	v.Sweep()
+	if n := testutil.CollectAndCount(v); n != 2 {
+		t.Fatalf("got %d series, want 2", n)
+	}
+
+	v.Set(prometheus.Labels{"name": "b"}, 3)
+	v.Set(prometheus.Labels{"name": "c"}, 4)
+	v.Sweep()
+	if n := testutil.CollectAndCount(v); n != 2 {
+		t.Fatalf("got %d series, want 2", n)
+	}
+	if got := testutil.ToFloat64(v.With(prometheus.Labels{"name": "b"})); got != 3 {
+		t.Errorf("got %v, want 3", got)
+	}
+	// "a" was deleted, With creates it anew with zero value
+	if got := testutil.ToFloat64(v.With(prometheus.Labels{"name": "a"})); got != 0 {
+		t.Errorf("got %v for deleted series, want 0", got)
+	}
+}
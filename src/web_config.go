@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/exporter-toolkit/web"
+	"go.yaml.in/yaml/v2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
+)
+
+// bcrypt hash of a random password, compared for unknown users so they can't be detected by timing
+const webFakePasswordHash = "$2y$10$QOauhQNbBCuQDKes6eFzPeMqBSjb7Mr5DUmpZ/VcEd00UAV/LDeSi"
+
+const webAuthCacheSize = 100
+
+const webConfigCheckInterval = 10 * time.Second
+
+// paths of the admin API always require authentication, regardless of --web.auth-paths
+const adminPathPrefix = "/admin/"
+
+// WebServer serves the mux according to exporter-toolkit web config (TLS, client certificates, basic auth users),
+// unlike exporter-toolkit it requires authentication only for paths listed in --web.auth-paths
+type WebServer struct {
+	path      string
+	authPaths []string
+	handler   http.Handler
+
+	mu      sync.Mutex
+	mtime   time.Time
+	config  *web.Config
+	limiter *rate.Limiter
+
+	// results of bcrypt comparisons, which are slow on purpose
+	bcryptMu  sync.Mutex
+	authCache *LruCache[string, bool]
+}
+
+func newWebServer(path string, authPaths string, handler http.Handler) (*WebServer, error) {
+	s := &WebServer{
+		path:      path,
+		handler:   handler,
+		authCache: NewLruCache[string, bool](webAuthCacheSize),
+	}
+	for p := range strings.SplitSeq(authPaths, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			s.authPaths = append(s.authPaths, p)
+		}
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadWebConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	s.mtime = stat.ModTime()
+	s.setConfig(config)
+	return s, nil
+}
+
+// loadWebConfig reads the config the same way as exporter-toolkit, including its defaults and validation
+func loadWebConfig(path string) (*web.Config, error) {
+	if err := web.Validate(path); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &web.Config{
+		TLSConfig: web.TLSConfig{
+			MinVersion: tls.VersionTLS12,
+			MaxVersion: tls.VersionTLS13,
+		},
+		HTTPConfig: web.HTTPConfig{HTTP2: true},
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+	config.TLSConfig.SetDirectory(filepath.Dir(path))
+	return config, nil
+}
+
+func (s *WebServer) setConfig(config *web.Config) {
+	s.config = config
+	s.limiter = nil
+	if config.RateLimiterConfig.Interval != 0 {
+		s.limiter = rate.NewLimiter(rate.Every(config.RateLimiterConfig.Interval), config.RateLimiterConfig.Burst)
+	}
+}
+
+func (s *WebServer) current() (*web.Config, *rate.Limiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config, s.limiter
+}
+
+// AuthConfigured tells if the current config has basic auth users or client certificates
+func (s *WebServer) AuthConfigured() bool {
+	config, _ := s.current()
+	return authConfigured(config)
+}
+
+// reloadIfChanged reloads the config when the file was changed, an invalid file keeps the previous config
+func (s *WebServer) reloadIfChanged() {
+	stat, err := os.Stat(s.path)
+	if err != nil {
+		slog.Error("Could not reload web config", "path", s.path, "err", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stat.ModTime().Equal(s.mtime) {
+		return
+	}
+	s.mtime = stat.ModTime()
+	config, err := loadWebConfig(s.path)
+	if err != nil {
+		slog.Error("Could not reload web config", "path", s.path, "err", err)
+		return
+	}
+	slog.Info("Reloaded web config", "path", s.path)
+	s.setConfig(config)
+}
+
+// watch reloads the config when the file changes until ctx is cancelled
+func (s *WebServer) watch(ctx context.Context) {
+	ticker := time.NewTicker(webConfigCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reloadIfChanged()
+		}
+	}
+}
+
+func (s *WebServer) ListenAndServe(server *http.Server) error {
+	server.Handler = s
+	config, _ := s.current()
+	if !config.TLSConfig.IsEnabled() {
+		slog.Info("TLS is disabled")
+		return server.ListenAndServe()
+	}
+
+	tlsConfig, err := web.ConfigToTLSConfig(&config.TLSConfig)
+	if err != nil {
+		return err
+	}
+	if !config.HTTPConfig.HTTP2 {
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	// certificates are reloaded on new connections
+	tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		config, _ := s.current()
+		result, err := web.ConfigToTLSConfig(&config.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
+		result.NextProtos = []string{"http/1.1"}
+		if config.HTTPConfig.HTTP2 {
+			result.NextProtos = []string{"h2", "http/1.1"}
+		}
+		return result, nil
+	}
+	server.TLSConfig = tlsConfig
+	slog.Info("TLS is enabled", "http2", config.HTTPConfig.HTTP2)
+	return server.ListenAndServeTLS("", "")
+}
+
+func (s *WebServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	config, limiter := s.current()
+
+	if limiter != nil && !limiter.Allow() {
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	for k, v := range config.HTTPConfig.Header {
+		w.Header().Set(k, v)
+	}
+
+	// admin routes are only registered with authentication configured, but it may be removed by a reload
+	isAdmin := strings.HasPrefix(r.URL.Path, adminPathPrefix)
+	if isAdmin && !authConfigured(config) {
+		http.Error(w, "admin API requires basic auth users or client certificates in web config", http.StatusForbidden)
+		return
+	}
+	if (isAdmin || s.requiresAuth(r.URL.Path)) && !s.authenticated(config, r) {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+// requiresAuth matches the path against --web.auth-paths: exactly, or by prefix for entries ending with "/"
+func (s *WebServer) requiresAuth(path string) bool {
+	for _, p := range s.authPaths {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func authConfigured(config *web.Config) bool {
+	clientCerts := config.TLSConfig.ClientCAs != "" || config.TLSConfig.ClientCAsText != ""
+	return len(config.Users) > 0 || clientCerts
+}
+
+// authenticated accepts a verified client certificate or a valid basic auth user
+func (s *WebServer) authenticated(config *web.Config, r *http.Request) bool {
+	if !authConfigured(config) {
+		return true
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, validUser := config.Users[user]
+	if !validUser {
+		hash = webFakePasswordHash
+	}
+
+	sum := sha256.Sum256([]byte(user + "\x00" + string(hash) + "\x00" + pass))
+	key := hex.EncodeToString(sum[:])
+	authOk, found := s.authCache.Get(key)
+	if !found {
+		s.bcryptMu.Lock()
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass))
+		s.bcryptMu.Unlock()
+		authOk = validUser && err == nil
+		s.authCache.Put(key, authOk)
+	}
+	return authOk
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeTestWebConfig(t *testing.T, path string, users map[string]string) {
+	t.Helper()
+	config := "basic_auth_users:\n"
+	if len(users) == 0 {
+		config = "{}\n"
+	}
+	for user, pass := range users {
+		hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config += "  " + user + ": " + string(hash) + "\n"
+	}
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestWebServer(t *testing.T, users map[string]string) (*WebServer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "web.yml")
+	writeTestWebConfig(t, path, users)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	s, err := newWebServer(path, "/metrics,/my/", mux)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func webStatus(s *WebServer, path string, user, pass string) int {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if user != "" {
+		r.SetBasicAuth(user, pass)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestWebServerAuth(t *testing.T) {
+	s, _ := newTestWebServer(t, map[string]string{"alice": "secret"})
+	if !s.AuthConfigured() {
+		t.Fatal("auth is not configured")
+	}
+
+	tests := []struct {
+		path       string
+		user, pass string
+		want       int
+	}{
+		{"/metrics", "", "", http.StatusUnauthorized},
+		{"/metrics", "alice", "wrong", http.StatusUnauthorized},
+		{"/metrics", "bob", "secret", http.StatusUnauthorized},
+		{"/metrics", "alice", "secret", http.StatusOK},
+		// entries ending with / match by prefix, others exactly
+		{"/my/events", "", "", http.StatusUnauthorized},
+		{"/my/events", "alice", "secret", http.StatusOK},
+		{"/metrics/global", "", "", http.StatusOK},
+		{"/offers", "", "", http.StatusOK},
+		// the admin API is always protected
+		{"/admin/status", "", "", http.StatusUnauthorized},
+		{"/admin/status", "alice", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		// the second request uses the cached bcrypt result
+		for range 2 {
+			if got := webStatus(s, tt.path, tt.user, tt.pass); got != tt.want {
+				t.Errorf("%s as %q: got %d, want %d", tt.path, tt.user, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestWebServerWithoutUsers(t *testing.T) {
+	s, _ := newTestWebServer(t, nil)
+	if s.AuthConfigured() {
+		t.Fatal("auth is configured without users")
+	}
+	if got := webStatus(s, "/metrics", "", ""); got != http.StatusOK {
+		t.Errorf("got %d for /metrics, want 200", got)
+	}
+	if got := webStatus(s, "/admin/status", "", ""); got != http.StatusForbidden {
+		t.Errorf("got %d for /admin/status, want 403", got)
+	}
+}
+
+func TestWebServerReload(t *testing.T) {
+	s, path := newTestWebServer(t, map[string]string{"alice": "secret"})
+
+	writeTestWebConfig(t, path, map[string]string{"bob": "other"})
+	// the config is only reloaded by the watcher
+	if got := webStatus(s, "/metrics", "bob", "other"); got != http.StatusUnauthorized {
+		t.Errorf("got %d before reload, want 401", got)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadIfChanged()
+	if got := webStatus(s, "/metrics", "bob", "other"); got != http.StatusOK {
+		t.Errorf("got %d for the new user, want 200", got)
+	}
+	if got := webStatus(s, "/metrics", "alice", "secret"); got != http.StatusUnauthorized {
+		t.Errorf("got %d for the removed user, want 401", got)
+	}
+
+	// an invalid config keeps the previous one
+	if err := os.WriteFile(path, []byte("unknown_field: 1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadIfChanged()
+	if got := webStatus(s, "/metrics", "bob", "other"); got != http.StatusOK {
+		t.Errorf("got %d after invalid config, want 200", got)
+	}
+}